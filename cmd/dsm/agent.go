@@ -0,0 +1,467 @@
+package dsm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	dsmSdk "github.com/senhasegura/dsmcli/sdk/dsm"
+)
+
+var Templates []string
+var AgentEnvFile string
+var ReloadSignal string
+var ReloadPid int
+var ReloadPidFile string
+var ReloadCommand string
+var HealthAddr string
+var Once bool
+
+// agentRetryDelay is the longest wait before fetching again after a failure.
+const agentRetryDelay = 30 * time.Second
+
+var AgentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Keep rendered secrets fresh, refreshing them as their TTL expires.",
+	Long: `Keep rendered secrets fresh, refreshing them as their TTL expires.
+
+The agent fetches the application secrets, renders them to the given templates and env file, and sleeps for the shortest TTL of the secrets, with jitter, before fetching them again. Files are only rewritten when their content changes, and only then is the supervised process reloaded, with a signal or a command.
+
+Templates are Go templates given as --template source:destination, executed with .Secrets holding the data of each secret by identity and .Data holding the keys of every secret, for example {{ .Data.DB_PASSWORD }} or {{ index .Secrets "database" "DB_PASSWORD" }}.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		agent, err := newAgent()
+		if err != nil {
+			return err
+		}
+
+		cmd.SilenceUsage = true
+
+		ctx := cmd.Context()
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		stopHealth, err := agent.serveHealth()
+		if err != nil {
+			return err
+		}
+		defer stopHealth()
+
+		return agent.run(cmd, ctx)
+	},
+}
+
+func init() {
+	applicationFlags(AgentCmd)
+	AgentCmd.Flags().BoolVarP(&Verbose, "verbose", "v", false, "Verbose mode")
+	AgentCmd.Flags().StringArrayVar(&Templates, "template", nil, "Template to render as source:destination, may be repeated")
+	AgentCmd.Flags().StringVar(&AgentEnvFile, "env-file", "", "Render every secret as KEY='value' lines to this file")
+	AgentCmd.Flags().StringVar(&ReloadSignal, "reload-signal", "HUP", "Signal sent to the supervised process when a file changes")
+	AgentCmd.Flags().IntVar(&ReloadPid, "reload-pid", 0, "Process to signal when a file changes")
+	AgentCmd.Flags().StringVar(&ReloadPidFile, "reload-pid-file", "", "File holding the process to signal when a file changes")
+	AgentCmd.Flags().StringVar(&ReloadCommand, "reload-command", "", "Command run with sh -c when a file changes")
+	AgentCmd.Flags().StringVar(&HealthAddr, "health-addr", "", "Address serving /healthz for liveness probes, e.g. :8080")
+	AgentCmd.Flags().BoolVar(&Once, "once", false, "Render the files once and exit")
+}
+
+// agentTemplate is a template rendered to a destination file.
+type agentTemplate struct {
+	tmpl        *template.Template
+	destination string
+}
+
+// agent writes the secrets on every refresh and reports its health.
+type agent struct {
+	apply     func(secrets dsmSdk.Secrets) error
+	once      bool
+	templates []agentTemplate
+	rendered  bool
+
+	// pendingReload is set when files changed since the supervised process
+	// was last reloaded successfully
+	pendingReload bool
+
+	// appResponse is the registered application, holding its credentials
+	appResponse dsmSdk.ApplicationResponse
+
+	mu          sync.Mutex
+	started     time.Time
+	lastSuccess time.Time
+	lastError   string
+	staleAfter  time.Duration
+}
+
+func newAgent() (*agent, error) {
+	if len(Templates) == 0 && AgentEnvFile == "" {
+		return nil, errors.Errorf("Provide at least one --template or the --env-file to render")
+	}
+
+	if ReloadPid != 0 || ReloadPidFile != "" {
+		if _, ok := reloadSignals[strings.ToUpper(strings.TrimPrefix(ReloadSignal, "SIG"))]; !ok {
+			return nil, errors.Errorf("Signal '%s' is invalid, it must be one of the following values: %s", ReloadSignal, strings.Join(signalNames(), ", "))
+		}
+	}
+
+	a := &agent{once: Once}
+	a.apply = a.render
+
+	for _, spec := range Templates {
+		parts := strings.SplitN(spec, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.Errorf("Invalid template '%s', it must be source:destination", spec)
+		}
+
+		content, err := ioutil.ReadFile(parts[0])
+		if err != nil {
+			return nil, errors.Wrap(err, "Error reading template")
+		}
+
+		tmpl, err := template.New(parts[0]).Funcs(templateFuncs).Option("missingkey=error").Parse(string(content))
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid template '%s'", parts[0])
+		}
+
+		a.templates = append(a.templates, agentTemplate{tmpl: tmpl, destination: parts[1]})
+	}
+
+	return a, nil
+}
+
+/**
+ * Fetches and renders the secrets until ctx is done
+ */
+func (a *agent) run(cmd *cobra.Command, ctx context.Context) error {
+	a.markStarted()
+
+	var appClient dsmSdk.ApplicationClient
+	registered := false
+
+	for {
+		var ttl time.Duration
+
+		secrets, err := a.fetch(cmd, &appClient, &registered)
+		if err == nil {
+			ttl = time.Duration(secrets.GetMinTTL()) * time.Second
+			err = a.apply(secrets)
+		}
+
+		a.report(err, ttl)
+
+		if a.once {
+			return err
+		}
+
+		wait := ttl
+		if err != nil {
+			v("Refreshing secrets failed: %s\n", err)
+
+			wait = agentRetryDelay
+			if ttl > 0 && ttl < wait {
+				wait = ttl
+			}
+		}
+
+		// Refresh early, between 80% and 100% of the wait, so several
+		// agents do not hit senhasegura at the same time
+		wait = wait - time.Duration(rand.Int63n(int64(wait)/5+1))
+		v("Refreshing secrets in %s\n", wait)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+/**
+ * Gets the secrets within the --timeout limit, registering the
+ * application on the first call
+ */
+func (a *agent) fetch(cmd *cobra.Command, appClient *dsmSdk.ApplicationClient, registered *bool) (dsmSdk.Secrets, error) {
+	ctx, cancel := commandContext(cmd)
+	defer cancel()
+
+	if !*registered {
+		client, appResponse, err := registerApplicationResponse(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		*appClient, *registered = client, true
+		a.appResponse = appResponse
+	}
+
+	return appClient.GetSecretsContext(ctx)
+}
+
+/**
+ * Renders every file, reloading the supervised process when any changed
+ * after the first render. A failed reload is tried again on the next
+ * render, even when nothing changed since.
+ */
+func (a *agent) render(secrets dsmSdk.Secrets) error {
+	data := templateData(secrets)
+
+	for _, t := range a.templates {
+		var content bytes.Buffer
+
+		err := t.tmpl.Execute(&content, data)
+		if err != nil {
+			return errors.Wrapf(err, "Error rendering template '%s'", t.tmpl.Name())
+		}
+
+		written, err := writeIfChanged(t.destination, content.Bytes())
+		if err != nil {
+			return err
+		}
+		a.pendingReload = a.pendingReload || (written && a.rendered)
+	}
+
+	if AgentEnvFile != "" {
+		var content bytes.Buffer
+		for _, variable := range secretVariables(secrets) {
+			content.WriteString(variable.Key + "=" + shellQuote(variable.Value) + "\n")
+		}
+
+		written, err := writeIfChanged(AgentEnvFile, content.Bytes())
+		if err != nil {
+			return err
+		}
+		a.pendingReload = a.pendingReload || (written && a.rendered)
+	}
+
+	// The supervised process reads the files when it starts
+	if !a.rendered {
+		a.rendered = true
+		return nil
+	}
+
+	if !a.pendingReload {
+		v("Secrets unchanged\n")
+		return nil
+	}
+
+	err := reload()
+	if err != nil {
+		return err
+	}
+
+	a.pendingReload = false
+
+	return nil
+}
+
+/**
+ * Writes content to path unless the file already holds it, reporting
+ * whether the file was written
+ */
+func writeIfChanged(path string, content []byte) (bool, error) {
+	current, err := ioutil.ReadFile(path)
+	if err == nil && bytes.Equal(current, content) {
+		return false, nil
+	}
+
+	v("Rendering %s\n", path)
+
+	err = writeFileAtomic(path, content)
+	if err != nil {
+		return false, errors.Wrapf(err, "Error writing %s", path)
+	}
+
+	return true, nil
+}
+
+/**
+ * Tells the supervised process the files changed, with the configured
+ * signal or command
+ */
+func reload() error {
+	pid := ReloadPid
+
+	if ReloadPidFile != "" {
+		content, err := ioutil.ReadFile(ReloadPidFile)
+		if err != nil {
+			return errors.Wrap(err, "Error reading pid file")
+		}
+
+		pid, err = strconv.Atoi(strings.TrimSpace(string(content)))
+		if err != nil {
+			return errors.Errorf("Invalid pid file '%s'", ReloadPidFile)
+		}
+	}
+
+	if pid != 0 {
+		process, err := os.FindProcess(pid)
+		if err != nil {
+			return err
+		}
+
+		v("Sending %s to process %d\n", ReloadSignal, pid)
+
+		err = process.Signal(reloadSignals[strings.ToUpper(strings.TrimPrefix(ReloadSignal, "SIG"))])
+		if err != nil {
+			return errors.Wrapf(err, "Error signaling process %d", pid)
+		}
+	}
+
+	if ReloadCommand != "" {
+		v("Running %s\n", ReloadCommand)
+
+		command := exec.Command("sh", "-c", ReloadCommand)
+		command.Stdout = os.Stdout
+		command.Stderr = os.Stderr
+
+		err := command.Run()
+		if err != nil {
+			return errors.Wrap(err, "Error running reload command")
+		}
+	}
+
+	return nil
+}
+
+/**
+ * Records the outcome of a refresh. The agent stays healthy until three
+ * refresh intervals pass without a successful one.
+ */
+func (a *agent) report(err error, ttl time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err != nil {
+		a.lastError = err.Error()
+		return
+	}
+
+	a.lastSuccess = time.Now()
+	a.lastError = ""
+	a.staleAfter = 3 * ttl
+}
+
+/**
+ * Serves /healthz on --health-addr, when given, returning a function
+ * stopping the server. The address is bound before returning, so a port
+ * already in use fails the command instead of leaving it without probes.
+ */
+func (a *agent) serveHealth() (func(), error) {
+	if HealthAddr == "" {
+		return func() {}, nil
+	}
+
+	listener, err := net.Listen("tcp", HealthAddr)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error serving the health endpoint")
+	}
+
+	a.markStarted()
+
+	server := &http.Server{Handler: a}
+
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && err != http.ErrServerClosed {
+			v("Health endpoint failed: %s\n", err)
+		}
+	}()
+
+	return func() { server.Close() }, nil
+}
+
+/**
+ * Records when the agent started, for the startup grace period
+ */
+func (a *agent) markStarted() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.started.IsZero() {
+		a.started = time.Now()
+	}
+}
+
+/**
+ * Returns how long the agent is reported healthy before its first
+ * successful refresh: a failed first attempt, the retry delay and a second
+ * attempt, each attempt taking up to --timeout
+ */
+func startupGrace() time.Duration {
+	return agentRetryDelay + 2*Timeout
+}
+
+/**
+ * Serves the health of the agent to liveness probes. Before the first
+ * successful refresh the agent is reported as starting, and healthy until
+ * the startup grace period ends, so a slow first fetch does not get the
+ * agent killed before it renders anything.
+ */
+func (a *agent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/healthz" {
+		http.NotFound(w, r)
+		return
+	}
+
+	a.mu.Lock()
+	starting := a.lastSuccess.IsZero()
+	healthy := time.Since(a.lastSuccess) < a.staleAfter
+	if starting {
+		healthy = time.Since(a.started) < startupGrace()
+	}
+	status := struct {
+		Healthy     bool      `json:"healthy"`
+		Starting    bool      `json:"starting,omitempty"`
+		LastSuccess time.Time `json:"last_success"`
+		LastError   string    `json:"last_error,omitempty"`
+	}{healthy, starting, a.lastSuccess, a.lastError}
+	a.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if !healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	json.NewEncoder(w).Encode(status)
+}
+
+/**
+ * Returns the data templates are executed with
+ */
+func templateData(secrets dsmSdk.Secrets) map[string]interface{} {
+	bySecret := make(map[string]map[string]string, len(secrets))
+	for _, secret := range secrets {
+		bySecret[secret.Identity] = secretData(secret)
+	}
+
+	return map[string]interface{}{
+		"Secrets": bySecret,
+		"Data":    convertJSONToKV(secrets),
+	}
+}
+
+func signalNames() []string {
+	names := make([]string, 0, len(reloadSignals))
+	for name := range reloadSignals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
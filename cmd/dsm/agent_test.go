@@ -0,0 +1,138 @@
+package dsm
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	dsmSdk "github.com/senhasegura/dsmcli/sdk/dsm"
+)
+
+func agentSecrets(password string) dsmSdk.Secrets {
+	return dsmSdk.Secrets{{Identity: "database", Data: []map[string]string{{"DB_PASSWORD": password}}}}
+}
+
+func TestRenderRetriesFailedReloads(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("reload commands run with sh")
+	}
+
+	dir := t.TempDir()
+	reloads := filepath.Join(dir, "reloads")
+
+	AgentEnvFile = filepath.Join(dir, "env")
+	t.Cleanup(func() { AgentEnvFile, ReloadCommand = "", "" })
+
+	a := &agent{}
+
+	// The first render is read by the process when it starts
+	err := a.render(agentSecrets("one"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ReloadCommand = "false"
+	if a.render(agentSecrets("two")) == nil {
+		t.Fatal("failed reload not reported")
+	}
+
+	// Nothing changed since, but the process still runs with the old secrets
+	if a.render(agentSecrets("two")) == nil {
+		t.Fatal("failed reload not tried again")
+	}
+
+	ReloadCommand = "echo reloaded >> " + reloads
+	for i := 0; i < 2; i++ {
+		err = a.render(agentSecrets("two"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	content, err := ioutil.ReadFile(reloads)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count := strings.Count(string(content), "reloaded"); count != 1 {
+		t.Errorf("process reloaded %d times, want once", count)
+	}
+}
+
+func TestServeHealthFailsOnUsedAddress(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	HealthAddr = listener.Addr().String()
+	t.Cleanup(func() { HealthAddr = "" })
+
+	_, err = (&agent{}).serveHealth()
+	if err == nil {
+		t.Fatal("health endpoint started on an address already in use")
+	}
+}
+
+func TestHealthStates(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name         string
+		agent        *agent
+		wantStatus   int
+		wantStarting bool
+	}{
+		{"starting", &agent{started: now}, http.StatusOK, true},
+		{"first refreshes failing", &agent{started: now.Add(-startupGrace() - time.Second), lastError: "boom"}, http.StatusServiceUnavailable, true},
+		{"healthy", &agent{started: now.Add(-time.Hour), lastSuccess: now, staleAfter: time.Minute}, http.StatusOK, false},
+		{"stale", &agent{started: now.Add(-time.Hour), lastSuccess: now.Add(-2 * time.Minute), staleAfter: time.Minute, lastError: "boom"}, http.StatusServiceUnavailable, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			tt.agent.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+
+			var status struct {
+				Healthy   bool   `json:"healthy"`
+				Starting  bool   `json:"starting"`
+				LastError string `json:"last_error"`
+			}
+
+			err := json.NewDecoder(recorder.Body).Decode(&status)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if status.Healthy != (tt.wantStatus == http.StatusOK) || status.Starting != tt.wantStarting {
+				t.Errorf("status = %+v, want healthy %t and starting %t", status, tt.wantStatus == http.StatusOK, tt.wantStarting)
+			}
+
+			if status.LastError != tt.agent.lastError {
+				t.Errorf("last_error = %q, want %q", status.LastError, tt.agent.lastError)
+			}
+		})
+	}
+}
+
+func TestHealthUnknownPath(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	(&agent{started: time.Now()}).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", recorder.Code)
+	}
+}
@@ -0,0 +1,154 @@
+package dsm
+
+import (
+	"context"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	dsmSdk "github.com/senhasegura/dsmcli/sdk/dsm"
+)
+
+// redacted replaces the values of secrets and credentials in the output.
+const redacted = "******"
+
+var Description string
+var Tags []string
+var ShowSecrets bool
+
+var AppCmd = &cobra.Command{
+	Use:   "app",
+	Short: "Manage the applications consuming secrets.",
+	Long: `Manage the applications consuming secrets.
+
+Every subcommand prints the response of senhasegura as JSON, with the application signature and the secret values redacted unless --show-secrets is set.`,
+}
+
+var AppRegisterCmd = &cobra.Command{
+	Use:   "register",
+	Short: "Register an application, or get it if it already exists.",
+	Long:  `Register an application, or get it if it already exists.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return appCommand(cmd, func(ctx context.Context, appClient *dsmSdk.ApplicationClient) (dsmSdk.ApplicationResponse, error) {
+			appClient.SetDetails(dsmSdk.ApplicationDetails{Description: Description, Tags: Tags})
+			return appClient.RegisterContext(ctx)
+		})
+	},
+}
+
+var AppShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show an application without registering it.",
+	Long:  `Show an application without registering it. The command exits with code 3 when the application does not exist.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return appCommand(cmd, func(ctx context.Context, appClient *dsmSdk.ApplicationClient) (dsmSdk.ApplicationResponse, error) {
+			return appClient.FindContext(ctx)
+		})
+	},
+}
+
+var AppUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update the description or tags of an application.",
+	Long:  `Update the description or tags of an application.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !cmd.Flags().Changed("description") && !cmd.Flags().Changed("tags") {
+			return errors.Errorf("Provide the --description or --tags to update")
+		}
+
+		return appCommand(cmd, func(ctx context.Context, appClient *dsmSdk.ApplicationClient) (dsmSdk.ApplicationResponse, error) {
+			return appClient.UpdateContext(ctx, dsmSdk.ApplicationDetails{Description: Description, Tags: Tags})
+		})
+	},
+}
+
+var AppDeleteCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Delete an application and its authorization.",
+	Long:  `Delete an application and its authorization.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return appCommand(cmd, func(ctx context.Context, appClient *dsmSdk.ApplicationClient) (dsmSdk.ApplicationResponse, error) {
+			return appClient.DeleteContext(ctx)
+		})
+	},
+}
+
+func init() {
+	AppCmd.PersistentFlags().BoolVarP(&Verbose, "verbose", "v", false, "Verbose mode")
+	AppCmd.PersistentFlags().BoolVar(&ShowSecrets, "show-secrets", false, "Print the application signature and the secret values instead of redacting them")
+
+	for _, cmd := range []*cobra.Command{AppRegisterCmd, AppShowCmd, AppUpdateCmd, AppDeleteCmd} {
+		applicationFlags(cmd)
+		AppCmd.AddCommand(cmd)
+	}
+
+	for _, cmd := range []*cobra.Command{AppRegisterCmd, AppUpdateCmd} {
+		cmd.Flags().StringVar(&Description, "description", "", "Application description")
+		cmd.Flags().StringSliceVar(&Tags, "tags", nil, "Application tags, separated by commas")
+	}
+}
+
+/**
+ * Runs an application request with the configured credentials and prints
+ * its response, redacted unless --show-secrets is set
+ */
+func appCommand(cmd *cobra.Command, call func(ctx context.Context, appClient *dsmSdk.ApplicationClient) (dsmSdk.ApplicationResponse, error)) error {
+	cmd.SilenceUsage = true
+
+	ctx, cancel := commandContext(cmd)
+	defer cancel()
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	appClient, err := dsmSdk.NewApplicationClient(&client, ApplicationName, Environment, System)
+	if err != nil {
+		return err
+	}
+
+	appResponse, err := call(ctx, &appClient)
+	if err != nil {
+		return err
+	}
+
+	if ShowSecrets {
+		return writeOutput(os.Stdout, "json", appResponse)
+	}
+
+	return writeOutput(os.Stdout, "json", redactApplication(appResponse))
+}
+
+/**
+ * Returns a copy of the response without the application signature and
+ * the values of its secrets
+ */
+func redactApplication(appResponse dsmSdk.ApplicationResponse) dsmSdk.ApplicationResponse {
+	if appResponse.Signature != "" {
+		appResponse.Signature = redacted
+	}
+
+	secrets := appResponse.Application.Secrets
+	appResponse.Application.Secrets = append(secrets[:0:0], secrets...)
+
+	for i, secret := range appResponse.Application.Secrets {
+		data := make([]map[string]string, len(secret.Data))
+
+		for j, entry := range secret.Data {
+			data[j] = make(map[string]string, len(entry))
+			for key := range entry {
+				data[j][key] = redacted
+			}
+		}
+
+		appResponse.Application.Secrets[i].Data = data
+	}
+
+	return appResponse
+}
@@ -0,0 +1,131 @@
+package dsm
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var ManifestFile string
+
+var CleanupCmd = &cobra.Command{
+	Use:   "cleanup",
+	Short: "Remove the secrets left behind by runb.",
+	Long: `Remove the secrets left behind by runb.
+
+Reads the manifest written by runb, overwrites and removes every secrets file it lists and deletes the variables runb created through the CI/CD tool's API. Variables that already existed are never deleted. Run it in the last stage of the pipeline, even when previous stages fail.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := ManifestFile
+		if path == "" {
+			path = manifestFilePath()
+		}
+
+		manifest, err := readManifest(path)
+		if os.IsNotExist(errors.Cause(err)) {
+			fmt.Println("Nothing to clean up, manifest not found:", path)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, file := range manifest.Files {
+			err = shredFile(file)
+			if os.IsNotExist(err) {
+				v("File %s already removed\n", file)
+				continue
+			}
+			if err != nil {
+				return errors.Wrapf(err, "Error removing %s", file)
+			}
+
+			fmt.Println("Removed", file)
+		}
+
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		for tool, paths := range manifest.CreatedVariables {
+			injector, err := GetInjector(tool)
+			if err != nil {
+				return err
+			}
+
+			revoker, ok := injector.(Revoker)
+			if !ok {
+				return errors.Errorf("Tool '%s' cannot delete the variables it created", tool)
+			}
+
+			deleted, err := revoker.Revoke(ctx, paths)
+			for _, path := range deleted {
+				fmt.Printf("Deleted %s variable %s\n", tool, path)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		err = os.Remove(path)
+		if err != nil {
+			return errors.Wrap(err, "Error removing manifest")
+		}
+
+		v("Removed manifest %s\n", path)
+
+		return nil
+	},
+}
+
+func init() {
+	CleanupCmd.Flags().BoolVarP(&Verbose, "verbose", "v", false, "Verbose mode")
+	CleanupCmd.Flags().StringVarP(&ManifestFile, "manifest", "m", "", "Manifest written by runb (default is SENHASEGURA_MANIFEST_FILE or .runb.manifest)")
+}
+
+/**
+ * Overwrites a regular file with zeros before removing it, so its content
+ * does not linger on disk. Symbolic links are refused.
+ */
+func shredFile(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	if !info.Mode().IsRegular() {
+		return errors.Errorf("%s is not a regular file", path)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.CopyN(file, zeroReader{}, info.Size())
+	if err == nil {
+		err = file.Sync()
+	}
+
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+// zeroReader is an endless source of zero bytes.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+
+	return len(p), nil
+}
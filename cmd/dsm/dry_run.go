@@ -0,0 +1,167 @@
+package dsm
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+
+	dsmSdk "github.com/senhasegura/dsmcli/sdk/dsm"
+)
+
+/**
+ * Prints what runb would send and write, never printing any value. found
+ * tells whether the application is already registered.
+ */
+func printPlan(w io.Writer, injector Injector, vars []Variable, found bool) error {
+	fmt.Fprintln(w, "Dry run, the application was only looked up, nothing will be registered, sent or written.")
+
+	if !found {
+		fmt.Fprintf(w, "\nApplication %s is not registered yet, it will be registered without access to any secret.\n", ApplicationName)
+	}
+
+	fmt.Fprintln(w, "\nEnvironment variables to be sent:")
+	if NoEnvUpload {
+		fmt.Fprintln(w, "  none, upload disabled with --no-env-upload")
+	} else {
+		environ, err := filterEnv(os.Environ())
+		if err != nil {
+			return err
+		}
+
+		printNames(w, envNames(environ))
+	}
+
+	fmt.Fprintln(w, "\nMapping file entries to be sent:")
+	if NoEnvUpload {
+		fmt.Fprintln(w, "  none, upload disabled with --no-env-upload")
+	} else {
+		err := printMapping(w)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(w, "\nSecrets to be injected:")
+	keys := make([]string, len(vars))
+	for i, variable := range vars {
+		keys[i] = variable.Key
+	}
+	printNames(w, keys)
+
+	describer, ok := injector.(Describer)
+	if !ok {
+		fmt.Fprintf(w, "\nTarget: %s tool\n", ToolName)
+		return nil
+	}
+
+	fmt.Fprintf(w, "\nTarget: %s\n", describer.Describe())
+
+	fmt.Fprintf(w, "\n%s variables to be deleted:\n", ToolName)
+	printNames(w, describer.DescribeCleanup(vars))
+
+	return nil
+}
+
+/**
+ * Prints the entries of the mapping file, flagging the variables it refers
+ * to that are missing from the environment
+ */
+func printMapping(w io.Writer) error {
+	path := viper.GetString("SENHASEGURA_MAPPING_FILE")
+	if path == "" {
+		fmt.Fprintln(w, "  none, SENHASEGURA_MAPPING_FILE is not set")
+		return nil
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "Error reading mapping file")
+	}
+
+	mapping, err := dsmSdk.ParseMapping(content)
+	if err == nil {
+		err = mapping.Validate()
+	}
+
+	if err != nil {
+		if StrictMapping {
+			return err
+		}
+
+		// runb sends the file anyway, senhasegura has the final word on it
+		fmt.Fprintf(w, "  warning: %v\n", err)
+	}
+
+	for _, entry := range mapping.AccessKeys {
+		fmt.Fprintf(w, "  access_keys %s (%s): %s\n", entry.Name, entry.Type, describeFields(
+			"access_key_id", entry.Fields.AccessKeyID,
+			"secret_access_key", entry.Fields.SecretAccessKey,
+		))
+	}
+
+	for _, entry := range mapping.Credentials {
+		fmt.Fprintf(w, "  credentials %s: %s\n", entry.Name, describeFields(
+			"user", entry.Fields.User,
+			"password", entry.Fields.Password,
+			"host", entry.Fields.Host,
+		))
+	}
+
+	for _, entry := range mapping.KeyValue {
+		fields := make([]string, len(entry.Fields))
+		for i, field := range entry.Fields {
+			fields[i] = describeVariable(field)
+		}
+
+		fmt.Fprintf(w, "  key_value %s: %s\n", entry.Name, strings.Join(fields, ", "))
+	}
+
+	return nil
+}
+
+/**
+ * Renders pairs of field and variable names, skipping unset fields
+ */
+func describeFields(pairs ...string) string {
+	var fields []string
+
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] != "" {
+			fields = append(fields, pairs[i]+"="+describeVariable(pairs[i+1]))
+		}
+	}
+
+	return strings.Join(fields, ", ")
+}
+
+func describeVariable(name string) string {
+	if _, ok := os.LookupEnv(name); !ok {
+		return name + " (missing)"
+	}
+
+	return name
+}
+
+func printNames(w io.Writer, names []string) {
+	if len(names) == 0 {
+		fmt.Fprintln(w, "  none")
+	}
+
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\n", name)
+	}
+}
+
+func envNames(environ []string) []string {
+	names := make([]string, len(environ))
+	for i, entry := range environ {
+		names[i] = strings.SplitN(entry, "=", 2)[0]
+	}
+
+	return names
+}
@@ -0,0 +1,103 @@
+package dsm
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+/**
+ * Serves the token and application endpoints of senhasegura, answering
+ * lookups with the given status and body and recording the requests
+ */
+func dsmServer(t *testing.T, status int, body string) *[]string {
+	t.Helper()
+
+	var mu sync.Mutex
+	var requests []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		mu.Unlock()
+
+		if r.URL.Path == "/iso/oauth2/token" {
+			w.Write([]byte(`{"access_token":"token","token_type":"Bearer","expires_in":3600}`))
+			return
+		}
+
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	setConfig(t, "SENHASEGURA_URL", server.URL)
+	setConfig(t, "SENHASEGURA_CLIENT_ID", "id")
+	setConfig(t, "SENHASEGURA_CLIENT_SECRET", "secret")
+	setConfig(t, "SENHASEGURA_MAPPING_FILE", "")
+
+	ApplicationName, System, Environment, ToolName, DryRun = "app", "sys", "prod", "linux", true
+	t.Cleanup(func() {
+		ApplicationName, System, Environment, ToolName, DryRun = "", "", "", "linux", false
+	})
+
+	return &requests
+}
+
+func TestDryRunLooksTheApplicationUp(t *testing.T) {
+	requests := dsmServer(t, http.StatusOK, `{"application":{"name":"app","secrets":[{"identity":"db","data":[{"DB_PASSWORD":"hunter2"}]}]}}`)
+
+	secrets, found, err := findSecrets(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !found {
+		t.Fatal("registered application reported as not found")
+	}
+
+	var plan bytes.Buffer
+	err = printPlan(&plan, injectors["linux"], secretVariables(secrets), found)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(plan.String(), "  DB_PASSWORD\n") {
+		t.Errorf("plan does not list the secret:\n%s", plan.String())
+	}
+
+	if strings.Contains(plan.String(), "hunter2") {
+		t.Errorf("plan prints the secret value:\n%s", plan.String())
+	}
+
+	want := []string{
+		"POST /iso/oauth2/token",
+		"GET /iso/dapp/Application?application=app&environment=prod&system=sys",
+	}
+	if strings.Join(*requests, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requests = %q, want %q", *requests, want)
+	}
+}
+
+func TestDryRunUnknownApplication(t *testing.T) {
+	requests := dsmServer(t, http.StatusNotFound, `{"response":{"status":404,"message":"Application not found","error":true}}`)
+
+	_, found, err := findSecrets(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if found {
+		t.Error("unknown application reported as found")
+	}
+
+	for _, request := range *requests {
+		if strings.HasPrefix(request, "POST /iso/dapp/") {
+			t.Errorf("dry run registered the application: %s", request)
+		}
+	}
+}
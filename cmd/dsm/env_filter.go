@@ -0,0 +1,76 @@
+package dsm
+
+import (
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// defaultEnvExclude are the credentials CI/CD tools and DSM CLI itself keep
+// in the environment, which are never sent to senhasegura.
+var defaultEnvExclude = []string{
+	"SENHASEGURA_CLIENT_SECRET",
+	"GITLAB_ACCESS_TOKEN",
+	"CI_JOB_TOKEN",
+	"CI_JOB_JWT*",
+	"CI_BUILD_TOKEN",
+	"CI_REGISTRY_PASSWORD",
+	"CI_DEPLOY_PASSWORD",
+	"CI_DEPENDENCY_PROXY_PASSWORD",
+	"GITHUB_TOKEN",
+	"ACTIONS_RUNTIME_TOKEN",
+	"ACTIONS_ID_TOKEN_REQUEST_TOKEN",
+	"SYSTEM_ACCESSTOKEN",
+	"BITBUCKET_STEP_OAUTH_ACCESS_TOKEN",
+	"BITBUCKET_STEP_OIDC_TOKEN",
+	"CIRCLE_OIDC_TOKEN*",
+	"BUILDKITE_AGENT_ACCESS_TOKEN",
+	"DRONE_NETRC_PASSWORD",
+}
+
+/**
+ * Returns the environment entries allowed by SENHASEGURA_ENV_INCLUDE and
+ * not matched by SENHASEGURA_ENV_EXCLUDE or the default exclusions
+ */
+func filterEnv(environ []string) ([]string, error) {
+	include := getList("SENHASEGURA_ENV_INCLUDE")
+	exclude := append(getList("SENHASEGURA_ENV_EXCLUDE"), defaultEnvExclude...)
+
+	for _, pattern := range append(include, exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, errors.Errorf("Invalid environment variable pattern '%s'", pattern)
+		}
+	}
+
+	var filtered []string
+
+	for _, entry := range environ {
+		name := strings.SplitN(entry, "=", 2)[0]
+
+		if len(include) > 0 && !matchAny(include, name) {
+			continue
+		}
+
+		if matchAny(exclude, name) {
+			continue
+		}
+
+		filtered = append(filtered, entry)
+	}
+
+	return filtered, nil
+}
+
+/**
+ * Reports whether name matches any of the glob patterns
+ */
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,123 @@
+package dsm
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+/**
+ * Reports whether key can be used as an environment variable name
+ * without being interpreted by a shell or CI tool
+ */
+func isValidVariableName(key string) bool {
+	return variableName.MatchString(key)
+}
+
+/**
+ * Quotes value as a single POSIX shell word. Inside single quotes nothing
+ * is special, so each quote is closed, escaped and reopened.
+ */
+func shellQuote(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
+
+/**
+ * Builds a shell command printing each argument on its own line, which
+ * unlike echo never interprets backslashes or options
+ */
+func shellPrintf(args ...string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+
+	return "printf '%s\\n' " + strings.Join(quoted, " ")
+}
+
+/**
+ * Returns a heredoc delimiter that does not occur in value
+ */
+func heredocDelimiter(value string) string {
+	for {
+		random := make([]byte, 8)
+		rand.Read(random)
+
+		delimiter := "ghadelimiter_" + hex.EncodeToString(random)
+		if !strings.Contains(value, delimiter) {
+			return delimiter
+		}
+	}
+}
+
+/**
+ * Escapes the data of a GitHub Actions workflow command
+ */
+func githubEscapeData(value string) string {
+	return strings.NewReplacer(
+		"%", "%25",
+		"\r", "%0D",
+		"\n", "%0A",
+	).Replace(value)
+}
+
+/**
+ * Escapes the message data of an Azure DevOps logging command
+ */
+func azureEscapeData(value string) string {
+	return strings.NewReplacer(
+		"%", "%AZP25",
+		"\r", "%0D",
+		"\n", "%0A",
+	).Replace(value)
+}
+
+/**
+ * Escapes a property of an Azure DevOps logging command
+ */
+func azureEscapeProperty(value string) string {
+	return strings.NewReplacer(
+		"%", "%AZP25",
+		"\r", "%0D",
+		"\n", "%0A",
+		";", "%3B",
+		"]", "%5D",
+	).Replace(value)
+}
+
+/**
+ * Escapes a value of a TeamCity service message
+ */
+func teamcityEscape(value string) string {
+	var b strings.Builder
+
+	for _, r := range value {
+		switch r {
+		case '|':
+			b.WriteString("||")
+		case '\'':
+			b.WriteString("|'")
+		case '\n':
+			b.WriteString("|n")
+		case '\r':
+			b.WriteString("|r")
+		case '[':
+			b.WriteString("|[")
+		case ']':
+			b.WriteString("|]")
+		case '\u0085':
+			b.WriteString("|x")
+		case '\u2028':
+			b.WriteString("|l")
+		case '\u2029':
+			b.WriteString("|p")
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,110 @@
+package dsm
+
+import (
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", `''`},
+		{"plain", `'plain'`},
+		{"it's", `'it'\''s'`},
+		{"''", `''\'''\'''`},
+		{"$(id) `id` $HOME", "'$(id) `id` $HOME'"},
+		{"a\nb", "'a\nb'"},
+	}
+
+	for _, tt := range tests {
+		if got := shellQuote(tt.value); got != tt.want {
+			t.Errorf("shellQuote(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestGithubEscapeData(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"plain", "plain"},
+		{"100%", "100%25"},
+		{"%0A", "%250A"},
+		{"a\nb", "a%0Ab"},
+		{"a\r\nb", "a%0D%0Ab"},
+		{"::set-env name=X::y", "::set-env name=X::y"},
+	}
+
+	for _, tt := range tests {
+		if got := githubEscapeData(tt.value); got != tt.want {
+			t.Errorf("githubEscapeData(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestAzureEscape(t *testing.T) {
+	tests := []struct {
+		value        string
+		wantData     string
+		wantProperty string
+	}{
+		{"plain", "plain", "plain"},
+		{"100%", "100%AZP25", "100%AZP25"},
+		{"a\r\nb", "a%0D%0Ab", "a%0D%0Ab"},
+		{"a;b]c", "a;b]c", "a%3Bb%5Dc"},
+		{"##vso[task.complete]", "##vso[task.complete]", "##vso[task.complete%5D"},
+	}
+
+	for _, tt := range tests {
+		if got := azureEscapeData(tt.value); got != tt.wantData {
+			t.Errorf("azureEscapeData(%q) = %q, want %q", tt.value, got, tt.wantData)
+		}
+		if got := azureEscapeProperty(tt.value); got != tt.wantProperty {
+			t.Errorf("azureEscapeProperty(%q) = %q, want %q", tt.value, got, tt.wantProperty)
+		}
+	}
+}
+
+func TestTeamcityEscape(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"plain", "plain"},
+		{"it's", "it|'s"},
+		{"a|b", "a||b"},
+		{"[a]", "|[a|]"},
+		{"a\r\nb", "a|r|nb"},
+		{"\u0085\u2028\u2029", "|x|l|p"},
+		{"$(id) 100%", "$(id) 100%"},
+	}
+
+	for _, tt := range tests {
+		if got := teamcityEscape(tt.value); got != tt.want {
+			t.Errorf("teamcityEscape(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestIsValidVariableName(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"DB_PASSWORD", true},
+		{"_private", true},
+		{"1PASSWORD", false},
+		{"DB-PASSWORD", false},
+		{"A=B", false},
+		{"A;rm -rf /", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := isValidVariableName(tt.key); got != tt.want {
+			t.Errorf("isValidVariableName(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
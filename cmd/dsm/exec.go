@@ -0,0 +1,111 @@
+package dsm
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	dsmSdk "github.com/senhasegura/dsmcli/sdk/dsm"
+)
+
+var ExecCmd = &cobra.Command{
+	Use:   "exec [flags] -- command [args...]",
+	Short: "Run a command with the application secrets injected into its environment.",
+	Long: `Run a command with the application secrets injected into its environment.
+
+Secrets are fetched from senhasegura DSM and handed to the child process as environment variables, without ever being written to disk. Signals received by dsm are forwarded to the child and dsm exits with the child's exit code.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		secrets, err := fetchSecrets(cmd)
+		if err != nil {
+			return err
+		}
+
+		code, err := run(args, convertJSONToKV(secrets))
+		if err != nil {
+			return err
+		}
+
+		if code != 0 {
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+			return exitStatus(code)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	ExecCmd.Flags().BoolVarP(&Verbose, "verbose", "v", false, "Verbose mode")
+	ExecCmd.Flags().StringVarP(&ApplicationName, "application", "a", "", "Application name (required)")
+	ExecCmd.Flags().StringVarP(&System, "system", "s", "", "Application system (required)")
+	ExecCmd.Flags().StringVarP(&Environment, "environment", "e", "", "Application environment (required)")
+	ExecCmd.MarkFlagRequired("application")
+	ExecCmd.MarkFlagRequired("system")
+	ExecCmd.MarkFlagRequired("environment")
+}
+
+// fetchSecrets gets the application secrets within the --timeout limit,
+// which does not apply to the child process.
+func fetchSecrets(cmd *cobra.Command) ([]dsmSdk.Secret, error) {
+	ctx, cancel := commandContext(cmd)
+	defer cancel()
+
+	appClient, err := registerApplication(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return appClient.GetSecretsContext(ctx)
+}
+
+// run starts args as a child process with secrets merged into the current
+// environment, forwarding signals to it until it exits. It returns the
+// child's exit code.
+func run(args []string, secrets map[string]string) (int, error) {
+	child := exec.Command(args[0], args[1:]...)
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+
+	child.Env = os.Environ()
+	for key, value := range secrets {
+		child.Env = append(child.Env, fmt.Sprintf("%s=%s", key, value))
+	}
+
+	v("Running %s with %d secrets in its environment\n", args[0], len(secrets))
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+
+	if err := child.Start(); err != nil {
+		signal.Stop(sigs)
+		return 0, errors.Wrapf(err, "Error starting %s", args[0])
+	}
+
+	go func() {
+		for sig := range sigs {
+			child.Process.Signal(sig)
+		}
+	}()
+
+	err := child.Wait()
+	signal.Stop(sigs)
+	close(sigs)
+
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		// Follow the shell convention for children killed by a signal.
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal()), nil
+		}
+		return exitErr.ExitCode(), nil
+	}
+
+	return 0, err
+}
@@ -0,0 +1,61 @@
+package dsm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	isoSdk "github.com/senhasegura/dsmcli/sdk/iso"
+)
+
+// Process exit codes, so scripts can react to the kind of failure.
+const (
+	ExitFailure      = 1
+	ExitUnauthorized = 2
+	ExitNotFound     = 3
+	ExitRateLimited  = 4
+	ExitAPIError     = 5
+	ExitConflict     = 6
+	ExitTimeout      = 124
+	ExitInterrupted  = 130
+)
+
+// exitStatus is returned by commands that must end the process with a given
+// code without reporting an error, such as exec mirroring its child.
+type exitStatus int
+
+func (e exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+/**
+ * Maps an error returned by a command to the process exit code
+ */
+func ExitCode(err error) int {
+	var apiErr *isoSdk.APIError
+	var status exitStatus
+
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &status):
+		return int(status)
+	case errors.Is(err, context.DeadlineExceeded):
+		return ExitTimeout
+	case errors.Is(err, context.Canceled):
+		return ExitInterrupted
+	case errors.Is(err, isoSdk.ErrUnauthorized):
+		return ExitUnauthorized
+	case errors.Is(err, isoSdk.ErrNotFound):
+		return ExitNotFound
+	case errors.Is(err, isoSdk.ErrRateLimited):
+		return ExitRateLimited
+	case errors.Is(err, isoSdk.ErrConflict):
+		return ExitConflict
+	case errors.As(err, &apiErr):
+		return ExitAPIError
+	default:
+		return ExitFailure
+	}
+}
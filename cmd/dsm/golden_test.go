@@ -0,0 +1,52 @@
+package dsm
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden files in testdata with the current output")
+
+// goldenVariables are rendered by the golden tests of every tool.
+var goldenVariables = []Variable{
+	{Key: "DB_USER", Value: "admin"},
+	{Key: "DB_PASSWORD", Value: "p@ss'w0rd$(id)`id`"},
+	{Key: "MULTI_LINE", Value: "-----BEGIN KEY-----\nMIIB\r\n-----END KEY-----"},
+	{Key: "UNICODE", Value: "café ☃ \u0085\u2028\u2029"},
+	{Key: "SPACED", Value: " leading and trailing "},
+	{Key: "SPECIAL", Value: `a=b:c#d!e|f[g]h%i;j\k`},
+	{Key: "EMPTY", Value: ""},
+}
+
+/**
+ * Compares got with testdata/name, rewriting the file instead when the
+ * tests run with -update
+ */
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+
+	if *update {
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err == nil {
+			err = ioutil.WriteFile(path, got, 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%s, run the tests with -update to create it", err)
+	}
+
+	if string(got) != string(want) {
+		t.Errorf("output does not match %s, run the tests with -update if the change is intended\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
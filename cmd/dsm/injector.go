@@ -0,0 +1,245 @@
+package dsm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// Variable is a secret key and value handed to an Injector.
+type Variable struct {
+	Key   string
+	Value string
+}
+
+// Injector delivers secrets to a CI/CD tool. Implementations are registered
+// by tool name with RegisterInjector and selected with the --tool flag.
+type Injector interface {
+	// Inject makes the variables available to the following pipeline steps.
+	Inject(ctx context.Context, vars []Variable) error
+
+	// Mask writes to w the commands that hide values from the tool's logs.
+	Mask(w io.Writer, values []string) error
+
+	// Cleanup removes the variables the tool keeps outside of the pipeline,
+	// once they have been handed over to senhasegura.
+	Cleanup(ctx context.Context, vars []Variable) error
+}
+
+// Revoker is implemented by injectors that create variables through the
+// tool's API, so the cleanup command can delete them at the end of the
+// pipeline. Revoke receives the paths recorded with recordCreatedVariable.
+type Revoker interface {
+	Revoke(ctx context.Context, paths []string) ([]string, error)
+}
+
+// Describer is optionally implemented by injectors to report, in the
+// --dry-run plan, where the variables are delivered and which of the tool's
+// variables the cleanup deletes.
+type Describer interface {
+	Describe() string
+	DescribeCleanup(vars []Variable) []string
+}
+
+var injectors = map[string]Injector{
+	"github":       fileInjector{write: writeGithub, mask: maskGithub, format: "commands appending to $GITHUB_ENV"},
+	"azure-devops": fileInjector{write: writeAzureDevops, mask: maskAzureDevops, format: "task.setvariable logging commands"},
+	"bamboo":       fileInjector{write: writeProperties, format: "Java properties"},
+	"bitbucket":    fileInjector{write: writeExport, format: "export statements"},
+	"circleci":     fileInjector{write: writeCircleci, format: "commands appending to $BASH_ENV"},
+	"teamcity":     teamcityInjector{fileInjector{write: writeTeamcity, format: "setParameter service messages"}},
+	"gitlab":       gitlabInjector{},
+	"jenkins":      fileInjector{write: writeProperties, format: "Java properties"},
+	"drone":        fileInjector{write: writeExport, format: "export statements"},
+	"buildkite":    fileInjector{write: writeExport, fileMask: maskBuildkite, format: "export statements"},
+	"tekton":       dirInjector{config: "SENHASEGURA_TEKTON_RESULTS_DIR", defaultDir: "/tekton/results"},
+	"argo":         dirInjector{config: "SENHASEGURA_ARGO_OUTPUTS_DIR", defaultDir: "/tmp/outputs"},
+	"linux":        fileInjector{write: writeLinux, format: "declare -x statements"},
+}
+
+/**
+ * Makes an injector available to the --tool flag, replacing any injector
+ * previously registered with the same name
+ */
+func RegisterInjector(name string, injector Injector) {
+	injectors[name] = injector
+}
+
+/**
+ * Returns the injector registered for a tool, including the custom tools
+ * declared in the configuration file
+ */
+func GetInjector(name string) (Injector, error) {
+	custom, err := customInjectors()
+	if err != nil {
+		return nil, err
+	}
+
+	if injector, ok := custom[name]; ok {
+		return injector, nil
+	}
+
+	if injector, ok := injectors[name]; ok {
+		return injector, nil
+	}
+
+	return nil, errors.Errorf("Tool '%s' is invalid, it must be one of the following values: %s", name, strings.Join(InjectorNames(), ", "))
+}
+
+/**
+ * Returns the sorted names of the registered injectors
+ */
+func InjectorNames() []string {
+	names := make([]string, 0, len(injectors))
+	for name := range injectors {
+		names = append(names, name)
+	}
+
+	for name := range viper.GetStringMap("SENHASEGURA_TOOLS") {
+		if _, ok := injectors[name]; !ok {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// maskFunc renders the command hiding a single value from a tool's logs.
+type maskFunc func(value string) string
+
+// fileInjector writes the variables to the secrets file, one writeFunc
+// output per variable, for tools that can only be reached from the shell.
+// Masking commands are printed by mask, or written to the secrets file by
+// fileMask for tools that only read them from there.
+type fileInjector struct {
+	write    writeFunc
+	mask     maskFunc
+	fileMask maskFunc
+	format   string
+}
+
+func (i fileInjector) Inject(ctx context.Context, vars []Variable) error {
+	var content bytes.Buffer
+
+	if i.fileMask != nil {
+		for _, value := range maskValues(vars) {
+			content.WriteString(i.fileMask(value))
+		}
+	}
+
+	for _, variable := range vars {
+		v("Injecting secret into %s: %s\n", secretsFilePath(), variable.Key)
+		content.WriteString(i.write(variable.Key, variable.Value))
+	}
+
+	return writeSecretsFile(content.Bytes())
+}
+
+func (i fileInjector) Mask(w io.Writer, values []string) error {
+	if i.mask == nil {
+		return nil
+	}
+
+	for _, value := range values {
+		if _, err := io.WriteString(w, i.mask(value)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (i fileInjector) Cleanup(ctx context.Context, vars []Variable) error {
+	v("Is not possible to delete %s variables!\n", ToolName)
+	return nil
+}
+
+func (i fileInjector) Describe() string {
+	return fmt.Sprintf("%s (%s)", secretsFilePath(), i.format)
+}
+
+func (i fileInjector) DescribeCleanup(vars []Variable) []string {
+	return nil
+}
+
+// teamcityInjector explains how values are hidden in TeamCity, which has no
+// service message to mask them.
+type teamcityInjector struct {
+	fileInjector
+}
+
+func (i teamcityInjector) Mask(w io.Writer, values []string) error {
+	// TeamCity has no service message to hide a value at runtime
+	fmt.Fprintln(os.Stderr, "Warning: TeamCity only hides parameters declared with the password type, declare the env. parameters as passwords in the build configuration or the secrets may show up in the build log")
+	return nil
+}
+
+// dirInjector writes each variable to a file named after its key, the way
+// Tekton results and Argo Workflows output parameters are read.
+type dirInjector struct {
+	config     string
+	defaultDir string
+}
+
+func (i dirInjector) Inject(ctx context.Context, vars []Variable) error {
+	dir := i.dir()
+
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	err = checkSecretsDir(dir, i.config)
+	if err != nil {
+		return err
+	}
+
+	for _, variable := range vars {
+		path := filepath.Join(dir, variable.Key)
+
+		v("Injecting secret into %s\n", path)
+
+		err = writeFileAtomic(path, []byte(variable.Value))
+		if err != nil {
+			return err
+		}
+
+		recordInjectedFile(path)
+	}
+
+	return nil
+}
+
+func (i dirInjector) Mask(w io.Writer, values []string) error {
+	return nil
+}
+
+func (i dirInjector) Cleanup(ctx context.Context, vars []Variable) error {
+	v("Is not possible to delete %s variables!\n", ToolName)
+	return nil
+}
+
+func (i dirInjector) Describe() string {
+	return fmt.Sprintf("one file per secret in %s", i.dir())
+}
+
+func (i dirInjector) DescribeCleanup(vars []Variable) []string {
+	return nil
+}
+
+func (i dirInjector) dir() string {
+	if dir := viper.GetString(i.config); dir != "" {
+		return dir
+	}
+
+	return i.defaultDir
+}
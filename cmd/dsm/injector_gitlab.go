@@ -0,0 +1,266 @@
+package dsm
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+
+	isoSdk "github.com/senhasegura/dsmcli/sdk/iso"
+)
+
+// maskableValue matches the values GitLab accepts for masked variables.
+var maskableValue = regexp.MustCompile(`^[A-Za-z0-9+/=@:.~_-]{8,}$`)
+
+// gitlabInjector writes a dotenv report for the following jobs and can also
+// create masked CI/CD variables. Its cleanup deletes the project variables
+// holding the secrets once they have been handed over to senhasegura.
+type gitlabInjector struct{}
+
+func (i gitlabInjector) Inject(ctx context.Context, vars []Variable) error {
+	var content bytes.Buffer
+
+	for _, variable := range vars {
+		line, err := writeGitlabDotenv(variable.Key, variable.Value)
+		if err != nil {
+			return err
+		}
+
+		v("Injecting secret into %s: %s\n", secretsFilePath(), variable.Key)
+		content.WriteString(line)
+	}
+
+	err := writeSecretsFile(content.Bytes())
+	if err != nil {
+		return err
+	}
+
+	if !viper.GetBool("SENHASEGURA_GITLAB_CREATE_VARIABLES") {
+		return nil
+	}
+
+	return createGitlabVars(ctx, vars)
+}
+
+func (i gitlabInjector) Mask(w io.Writer, values []string) error {
+	return nil
+}
+
+func (i gitlabInjector) Cleanup(ctx context.Context, vars []Variable) error {
+	if viper.GetBool("SENHASEGURA_GITLAB_CREATE_VARIABLES") {
+		v("Keeping the %s variables created for the following jobs\n", ToolName)
+		return nil
+	}
+
+	if !IsSet("GITLAB_ACCESS_TOKEN", "CI_API_V4_URL", "CI_PROJECT_ID") {
+		v("Deletion failed\n")
+		v("To delete gitlab variables, you need to define the configs GITLAB_ACCESS_TOKEN, CI_API_V4_URL and CI_PROJECT_ID\n")
+		return nil
+	}
+
+	for _, variable := range vars {
+		v("Deleting %s variable\n", variable.Key)
+
+		_, err := gitlabRequest(
+			ctx,
+			http.MethodDelete,
+			"/projects/"+viper.GetString("CI_PROJECT_ID")+"/variables/"+variable.Key,
+			url.Values{},
+		)
+
+		if err != nil {
+			v("Failed trying to delete '%s' variable\n", err.Error())
+			continue
+		}
+
+		v("Deleted\n")
+	}
+
+	return nil
+}
+
+func (i gitlabInjector) Describe() string {
+	target := secretsFilePath() + " (dotenv report)"
+
+	if viper.GetBool("SENHASEGURA_GITLAB_CREATE_VARIABLES") {
+		target += " and CI/CD variables in " + gitlabVariablesScope()
+	}
+
+	return target
+}
+
+func (i gitlabInjector) DescribeCleanup(vars []Variable) []string {
+	if viper.GetBool("SENHASEGURA_GITLAB_CREATE_VARIABLES") {
+		return nil
+	}
+
+	keys := make([]string, len(vars))
+	for n, variable := range vars {
+		keys[n] = "/projects/" + viper.GetString("CI_PROJECT_ID") + "/variables/" + variable.Key
+	}
+
+	return keys
+}
+
+/**
+ * Deletes the variables created by Inject, given by their API paths, and
+ * returns the paths deleted. Variables already gone are skipped.
+ */
+func (i gitlabInjector) Revoke(ctx context.Context, paths []string) ([]string, error) {
+	if !IsSet("GITLAB_ACCESS_TOKEN", "CI_API_V4_URL") {
+		return nil, errors.Errorf("To delete gitlab variables, you need to define the configs GITLAB_ACCESS_TOKEN and CI_API_V4_URL")
+	}
+
+	var deleted []string
+
+	for _, path := range paths {
+		_, err := gitlabRequest(ctx, http.MethodDelete, path, url.Values{})
+		if errors.Is(err, isoSdk.ErrNotFound) {
+			v("Variable %s not found, skipping\n", path)
+			continue
+		}
+		if err != nil {
+			return deleted, errors.Wrapf(err, "Failed trying to delete '%s' variable", path)
+		}
+
+		deleted = append(deleted, path)
+	}
+
+	return deleted, nil
+}
+
+/**
+ * Renders a line of a GitLab dotenv report. The format has no quoting or
+ * escaping, so values spanning several lines cannot be represented.
+ */
+func writeGitlabDotenv(key, value string) (string, error) {
+	if strings.ContainsAny(value, "\r\n") {
+		return "", errors.Errorf("Secret '%s' spans several lines, which GitLab dotenv reports do not support", key)
+	}
+
+	if strings.TrimSpace(value) != value {
+		v("Leading and trailing spaces of secret '%s' are removed by GitLab\n", key)
+	}
+
+	return key + "=" + value + "\n", nil
+}
+
+/**
+ * Creates or updates the CI/CD variables of the project, or of the group
+ * set in SENHASEGURA_GITLAB_GROUP_ID, masking every value GitLab can mask
+ */
+func createGitlabVars(ctx context.Context, vars []Variable) error {
+	if !IsSet("GITLAB_ACCESS_TOKEN", "CI_API_V4_URL") {
+		return errors.Errorf("To create gitlab variables, you need to define the configs GITLAB_ACCESS_TOKEN and CI_API_V4_URL")
+	}
+
+	scope := gitlabVariablesScope()
+	if scope == "" {
+		return errors.Errorf("To create gitlab variables, you need to define the config CI_PROJECT_ID or SENHASEGURA_GITLAB_GROUP_ID")
+	}
+
+	protected := true
+	if viper.IsSet("SENHASEGURA_GITLAB_PROTECTED") {
+		protected = viper.GetBool("SENHASEGURA_GITLAB_PROTECTED")
+	}
+
+	for _, variable := range vars {
+		masked := maskableValue.MatchString(variable.Value)
+		if !masked {
+			v("Secret '%s' cannot be masked by GitLab, creating it unmasked\n", variable.Key)
+		}
+
+		data := url.Values{
+			"key":           {variable.Key},
+			"value":         {variable.Value},
+			"variable_type": {"env_var"},
+			"masked":        {strconv.FormatBool(masked)},
+			"protected":     {strconv.FormatBool(protected)},
+		}
+
+		v("Creating %s variable\n", variable.Key)
+
+		body, err := gitlabRequest(ctx, http.MethodPost, scope, data)
+		if err == nil {
+			recordCreatedVariable(scope + "/" + variable.Key)
+			continue
+		}
+
+		if gitlabVariableExists(err, body) {
+			// The variable already exists, update it instead. It is not
+			// recorded, so the cleanup keeps variables it did not create.
+			_, err = gitlabRequest(ctx, http.MethodPut, scope+"/"+variable.Key, data)
+		}
+
+		if err != nil {
+			return errors.Wrapf(err, "Failed trying to create '%s' variable", variable.Key)
+		}
+	}
+
+	return nil
+}
+
+/**
+ * Reports whether a failed creation was rejected because the key is
+ * already used, the only 400 error worth retrying as an update
+ */
+func gitlabVariableExists(err error, body []byte) bool {
+	var apiErr *isoSdk.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		return false
+	}
+
+	return strings.Contains(string(body), "has already been taken")
+}
+
+/**
+ * Returns the API path of the variables of the configured group or project
+ */
+func gitlabVariablesScope() string {
+	if group := viper.GetString("SENHASEGURA_GITLAB_GROUP_ID"); group != "" {
+		return "/groups/" + group + "/variables"
+	}
+
+	if project := viper.GetString("CI_PROJECT_ID"); project != "" {
+		return "/projects/" + project + "/variables"
+	}
+
+	return ""
+}
+
+/**
+ * Performs a request on the GitLab API found at CI_API_V4_URL
+ */
+func gitlabRequest(ctx context.Context, method string, path string, data url.Values) ([]byte, error) {
+	api, err := url.Parse(viper.GetString("CI_API_V4_URL"))
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient, err := isoSdk.NewHTTPClient(getGitlabTLSOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	headers := map[string]string{
+		"PRIVATE-TOKEN": viper.GetString("GITLAB_ACCESS_TOKEN"),
+		"Content-Type":  "application/x-www-form-urlencoded",
+	}
+
+	return isoSdk.DoRequestWithClient(
+		ctx,
+		httpClient,
+		api.Scheme+"://"+api.Host,
+		strings.TrimRight(api.Path, "/")+path,
+		data,
+		headers,
+		method,
+	)
+}
@@ -0,0 +1,79 @@
+package dsm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	isoSdk "github.com/senhasegura/dsmcli/sdk/iso"
+)
+
+/**
+ * Serves the variables API of a GitLab project, answering creations with
+ * the given status and body and recording the requests
+ */
+func gitlabServer(t *testing.T, status int, body string) *[]string {
+	t.Helper()
+
+	var requests []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+
+		if r.Method == http.MethodPost {
+			w.WriteHeader(status)
+			w.Write([]byte(body))
+			return
+		}
+
+		w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+
+	setConfig(t, "CI_API_V4_URL", server.URL+"/api/v4")
+	setConfig(t, "GITLAB_ACCESS_TOKEN", "token")
+	setConfig(t, "CI_PROJECT_ID", "42")
+
+	createdVariables = nil
+	t.Cleanup(func() { createdVariables = nil })
+
+	return &requests
+}
+
+func TestCreateGitlabVarsUpdatesTakenKeys(t *testing.T) {
+	requests := gitlabServer(t, http.StatusBadRequest, `{"message":{"key":["(SECRET) has already been taken"]}}`)
+
+	err := createGitlabVars(context.Background(), []Variable{{Key: "SECRET", Value: "value1234"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"POST /api/v4/projects/42/variables",
+		"PUT /api/v4/projects/42/variables/SECRET",
+	}
+	if len(*requests) != len(want) || (*requests)[0] != want[0] || (*requests)[1] != want[1] {
+		t.Errorf("requests = %q, want %q", *requests, want)
+	}
+
+	if len(createdVariables) != 0 {
+		t.Errorf("updated variable was recorded as created: %q", createdVariables)
+	}
+}
+
+func TestCreateGitlabVarsReturnsOtherBadRequests(t *testing.T) {
+	requests := gitlabServer(t, http.StatusBadRequest, `{"message":{"value":["is invalid"]}}`)
+
+	err := createGitlabVars(context.Background(), []Variable{{Key: "SECRET", Value: "value1234"}})
+
+	var apiErr *isoSdk.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("err = %v, want the 400 error of the creation", err)
+	}
+
+	if len(*requests) != 1 {
+		t.Errorf("requests = %q, want only the creation", *requests)
+	}
+}
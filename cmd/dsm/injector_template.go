@@ -0,0 +1,148 @@
+package dsm
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// customTool is a tool declared under SENHASEGURA_TOOLS in the config file.
+type customTool struct {
+	// Template renders each variable into the secrets file.
+	Template string `mapstructure:"template"`
+
+	// Mask optionally renders the command hiding each value from the logs.
+	Mask string `mapstructure:"mask"`
+}
+
+// templateFuncs are the escaping helpers available to custom templates.
+var templateFuncs = template.FuncMap{
+	"shell":      shellQuote,
+	"properties": func(value string) string { return propertiesEscape(value, false) },
+	"azure":      azureEscapeData,
+	"github":     githubEscapeData,
+	"teamcity":   teamcityEscape,
+	"base64":     func(value string) string { return base64.StdEncoding.EncodeToString([]byte(value)) },
+	"json": func(value string) (string, error) {
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	},
+}
+
+// templateInjector writes the secrets file with user provided templates,
+// executed once per variable with .Key and .Value.
+type templateInjector struct {
+	write *template.Template
+	mask  *template.Template
+}
+
+/**
+ * Builds the injectors declared in the configuration file, for example:
+ *
+ *	SENHASEGURA_TOOLS:
+ *	  dotenv:
+ *	    template: "{{ .Key }}={{ shell .Value }}"
+ */
+func customInjectors() (map[string]Injector, error) {
+	var tools map[string]customTool
+
+	err := viper.UnmarshalKey("SENHASEGURA_TOOLS", &tools)
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid SENHASEGURA_TOOLS configuration")
+	}
+
+	custom := make(map[string]Injector, len(tools))
+
+	for name, tool := range tools {
+		if tool.Template == "" {
+			return nil, errors.Errorf("Tool '%s' must define a template", name)
+		}
+
+		injector := templateInjector{}
+
+		injector.write, err = template.New(name).Funcs(templateFuncs).Parse(tool.Template)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid template for tool '%s'", name)
+		}
+
+		if tool.Mask != "" {
+			injector.mask, err = template.New(name + "-mask").Funcs(templateFuncs).Parse(tool.Mask)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Invalid mask template for tool '%s'", name)
+			}
+		}
+
+		custom[name] = injector
+	}
+
+	return custom, nil
+}
+
+func (i templateInjector) Inject(ctx context.Context, vars []Variable) error {
+	var content bytes.Buffer
+
+	for _, variable := range vars {
+		v("Injecting secret into %s: %s\n", secretsFilePath(), variable.Key)
+
+		err := render(&content, i.write, variable)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writeSecretsFile(content.Bytes())
+}
+
+func (i templateInjector) Mask(w io.Writer, values []string) error {
+	if i.mask == nil {
+		return nil
+	}
+
+	for _, value := range values {
+		err := render(w, i.mask, Variable{Value: value})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (i templateInjector) Cleanup(ctx context.Context, vars []Variable) error {
+	v("Is not possible to delete %s variables!\n", ToolName)
+	return nil
+}
+
+func (i templateInjector) Describe() string {
+	return secretsFilePath() + " (custom template)"
+}
+
+func (i templateInjector) DescribeCleanup(vars []Variable) []string {
+	return nil
+}
+
+/**
+ * Executes tmpl for a variable, ending its output with a line break
+ */
+func render(w io.Writer, tmpl *template.Template, variable Variable) error {
+	var out strings.Builder
+
+	err := tmpl.Execute(&out, variable)
+	if err != nil {
+		return errors.Wrapf(err, "Error rendering template '%s'", tmpl.Name())
+	}
+
+	if !strings.HasSuffix(out.String(), "\n") {
+		out.WriteString("\n")
+	}
+
+	_, err = io.WriteString(w, out.String())
+	return err
+}
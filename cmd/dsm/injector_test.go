@@ -0,0 +1,168 @@
+package dsm
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+/**
+ * Sets a config key for the duration of the test
+ */
+func setConfig(t *testing.T, key string, value string) {
+	t.Helper()
+
+	viper.Set(key, value)
+	t.Cleanup(func() { viper.Set(key, "") })
+}
+
+func checkPrivateMode(t *testing.T, path string) {
+	t.Helper()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Windows does not report Unix permissions
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("%s has mode %o, want 600", path, info.Mode().Perm())
+	}
+}
+
+func TestFileInjectorsGolden(t *testing.T) {
+	for _, tool := range []string{"jenkins", "drone", "buildkite"} {
+		t.Run(tool, func(t *testing.T) {
+			secretsFile := filepath.Join(t.TempDir(), "secrets")
+			setConfig(t, "SENHASEGURA_SECRETS_FILE", secretsFile)
+
+			injector, err := GetInjector(tool)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = injector.Inject(context.Background(), goldenVariables)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			checkPrivateMode(t, secretsFile)
+
+			content, err := ioutil.ReadFile(secretsFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			checkGolden(t, filepath.Join("injectors", tool+".golden"), content)
+		})
+	}
+}
+
+func TestBuildkiteMasksBeforeExporting(t *testing.T) {
+	secretsFile := filepath.Join(t.TempDir(), "secrets")
+	setConfig(t, "SENHASEGURA_SECRETS_FILE", secretsFile)
+
+	err := injectors["buildkite"].Inject(context.Background(), goldenVariables)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(secretsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var prefix strings.Builder
+	for _, value := range maskValues(goldenVariables) {
+		prefix.WriteString(maskBuildkite(value))
+	}
+
+	if !strings.HasPrefix(string(content), prefix.String()) {
+		t.Errorf("secrets file does not start with the redactor commands of every value:\n%s", content)
+	}
+
+	if !strings.HasPrefix(string(content[prefix.Len():]), "export ") {
+		t.Errorf("the redactor commands are not followed by the exports:\n%s", content)
+	}
+}
+
+func TestDirInjectorsGolden(t *testing.T) {
+	tests := []struct {
+		tool   string
+		config string
+	}{
+		{"tekton", "SENHASEGURA_TEKTON_RESULTS_DIR"},
+		{"argo", "SENHASEGURA_ARGO_OUTPUTS_DIR"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tool, func(t *testing.T) {
+			// The directory is created when it does not exist
+			dir := filepath.Join(t.TempDir(), "results")
+			setConfig(t, tt.config, dir)
+
+			err := injectors[tt.tool].Inject(context.Background(), goldenVariables)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			files, err := ioutil.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// One file per variable, named after its key and holding its value
+			var layout strings.Builder
+			for _, file := range files {
+				path := filepath.Join(dir, file.Name())
+				checkPrivateMode(t, path)
+
+				content, err := ioutil.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				fmt.Fprintf(&layout, "%s: %q\n", file.Name(), content)
+			}
+
+			if len(files) != len(goldenVariables) {
+				t.Errorf("%d files written, want %d", len(files), len(goldenVariables))
+			}
+
+			checkGolden(t, filepath.Join("injectors", tt.tool+".golden"), []byte(layout.String()))
+		})
+	}
+}
+
+func TestDirInjectorRefusesWorldWritableDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("world-writable directories are not detected on Windows")
+	}
+
+	dir := t.TempDir()
+	err := os.Chmod(dir, 0777)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setConfig(t, "SENHASEGURA_ARGO_OUTPUTS_DIR", dir)
+
+	err = injectors["argo"].Inject(context.Background(), goldenVariables)
+	if err == nil || !strings.Contains(err.Error(), "SENHASEGURA_ARGO_OUTPUTS_DIR") {
+		t.Fatalf("err = %v, want a refusal naming SENHASEGURA_ARGO_OUTPUTS_DIR", err)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("%d files written to a world-writable directory", len(files))
+	}
+}
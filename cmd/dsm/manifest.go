@@ -0,0 +1,137 @@
+package dsm
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// Manifest records what the runb executions of a job left behind, so the
+// cleanup command can remove it at the end of the pipeline. It never holds
+// values.
+type Manifest struct {
+	Tool      string   `json:"tool"`
+	Files     []string `json:"files"`
+	Variables []string `json:"variables"`
+	// CreatedVariables are the API paths of the variables created through
+	// each tool, by tool name.
+	CreatedVariables map[string][]string `json:"created_variables,omitempty"`
+	CreatedAt        time.Time           `json:"created_at"`
+}
+
+// injectedFiles are the files written with secrets by this execution.
+var injectedFiles []string
+
+// createdVariables are the variables created through the tool's API by this
+// execution, as recorded by recordCreatedVariable.
+var createdVariables []string
+
+func manifestFilePath() string {
+	manifestFile := viper.GetString("SENHASEGURA_MANIFEST_FILE")
+	if manifestFile == "" {
+		manifestFile = ".runb.manifest"
+	}
+
+	return manifestFile
+}
+
+/**
+ * Records the files written and the variables injected with the given tool,
+ * merged with the manifest of previous executions in the same job so the
+ * cleanup removes everything they left behind
+ */
+func writeManifest(tool string, vars []Variable) error {
+	manifest, err := readManifest(manifestFilePath())
+	if os.IsNotExist(errors.Cause(err)) {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, len(vars))
+	for i, variable := range vars {
+		keys[i] = variable.Key
+	}
+
+	manifest.Tool = tool
+	manifest.Files = appendUnique(manifest.Files, injectedFiles...)
+	manifest.Variables = appendUnique(manifest.Variables, keys...)
+	manifest.CreatedAt = time.Now().UTC()
+
+	if len(createdVariables) > 0 {
+		if manifest.CreatedVariables == nil {
+			manifest.CreatedVariables = make(map[string][]string)
+		}
+		manifest.CreatedVariables[tool] = appendUnique(manifest.CreatedVariables[tool], createdVariables...)
+	}
+
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	v("Writing manifest to %s\n", manifestFilePath())
+
+	return writeFileAtomic(manifestFilePath(), content)
+}
+
+func readManifest(path string) (Manifest, error) {
+	var manifest Manifest
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return manifest, errors.Wrap(err, "Error reading manifest")
+	}
+
+	err = json.Unmarshal(content, &manifest)
+	if err != nil {
+		return manifest, errors.Wrapf(err, "Invalid manifest '%s'", path)
+	}
+
+	return manifest, nil
+}
+
+/**
+ * Remembers a file written with secrets, by absolute path so the cleanup
+ * can run from another directory
+ */
+func recordInjectedFile(path string) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	injectedFiles = appendUnique(injectedFiles, path)
+}
+
+/**
+ * Remembers a variable created through the tool's API by its API path, so
+ * the cleanup deletes exactly the variables DSM CLI created
+ */
+func recordCreatedVariable(path string) {
+	createdVariables = appendUnique(createdVariables, path)
+}
+
+/**
+ * Appends the values not yet in list
+ */
+func appendUnique(list []string, values ...string) []string {
+	seen := make(map[string]bool, len(list))
+	for _, value := range list {
+		seen[value] = true
+	}
+
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			list = append(list, value)
+		}
+	}
+
+	return list
+}
@@ -0,0 +1,93 @@
+package dsm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	dsmSdk "github.com/senhasegura/dsmcli/sdk/dsm"
+)
+
+var MappingCmd = &cobra.Command{
+	Use:   "mapping",
+	Short: "Manage the mapping file registering pipeline variables as secrets.",
+	Long:  `Manage the mapping file registering pipeline variables as secrets.`,
+}
+
+var MappingValidateCmd = &cobra.Command{
+	Use:   "validate [file]",
+	Short: "Check a mapping file before it is used by runb.",
+	Long: `Check a mapping file before it is used by runb.
+
+The file, SENHASEGURA_MAPPING_FILE by default, must be valid JSON or YAML following the mapping schema, with unique names and every variable it refers to defined in the current environment.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := viper.GetString("SENHASEGURA_MAPPING_FILE")
+		if len(args) > 0 {
+			path = args[0]
+		}
+
+		if path == "" {
+			return errors.Errorf("Provide the mapping file as argument or through SENHASEGURA_MAPPING_FILE")
+		}
+
+		// Problems in the file are not usage errors
+		cmd.SilenceUsage = true
+
+		mapping, err := readMapping(path)
+		if err != nil {
+			return err
+		}
+
+		var problems []string
+
+		var mappingErr *dsmSdk.MappingError
+		if errors.As(mapping.Validate(), &mappingErr) {
+			problems = append(problems, mappingErr.Problems...)
+		}
+
+		for _, name := range mapping.Variables() {
+			if _, ok := os.LookupEnv(name); !ok {
+				problems = append(problems, fmt.Sprintf("variable %s is not defined in the environment", name))
+			}
+		}
+
+		if len(problems) > 0 {
+			for _, problem := range problems {
+				fmt.Println(problem)
+			}
+
+			return errors.Errorf("Mapping file '%s' has %d problem(s)", path, len(problems))
+		}
+
+		fmt.Printf("Mapping file '%s' is valid\n", path)
+
+		return nil
+	},
+}
+
+func init() {
+	MappingCmd.PersistentFlags().BoolVarP(&Verbose, "verbose", "v", false, "Verbose mode")
+	MappingCmd.AddCommand(MappingValidateCmd)
+}
+
+/**
+ * Reads and parses a mapping file written in JSON or YAML
+ */
+func readMapping(path string) (dsmSdk.Mapping, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return dsmSdk.Mapping{}, errors.Wrap(err, "Error reading mapping file")
+	}
+
+	mapping, err := dsmSdk.ParseMapping(content)
+	if err != nil {
+		return mapping, errors.Wrapf(err, "Mapping file '%s'", path)
+	}
+
+	return mapping, nil
+}
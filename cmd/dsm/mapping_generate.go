@@ -0,0 +1,390 @@
+package dsm
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	dsmSdk "github.com/senhasegura/dsmcli/sdk/dsm"
+)
+
+var EnvFile string
+var MappingOutput string
+var MappingFormat string
+var Interactive bool
+
+var MappingGenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate a mapping file from the variables of the environment.",
+	Long: `Generate a mapping file from the variables of the environment.
+
+Variables are read from the current environment, or from a .env file with --env-file, and grouped by name: access key ID and secret access key pairs become access_keys, user and password variables sharing a prefix become credentials and the remaining variables that look like secrets become key_value fields. Only variable names are written, never their values.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		environ := os.Environ()
+
+		if EnvFile != "" {
+			var err error
+
+			environ, err = readEnvFile(EnvFile)
+			if err != nil {
+				return err
+			}
+		}
+
+		environ, err := filterEnv(environ)
+		if err != nil {
+			return err
+		}
+
+		mapping := generateMapping(envNames(environ))
+
+		if Interactive {
+			mapping, err = confirmMapping(bufio.NewReader(os.Stdin), os.Stderr, mapping)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = mapping.Validate()
+		if err != nil {
+			return err
+		}
+
+		format := MappingFormat
+		if format == "" {
+			format = "json"
+			if ext := filepath.Ext(MappingOutput); ext == ".yaml" || ext == ".yml" {
+				format = "yaml"
+			}
+		}
+
+		content, err := encodeMapping(mapping, format)
+		if err != nil {
+			return err
+		}
+
+		if MappingOutput == "" {
+			_, err = os.Stdout.Write(content)
+			return err
+		}
+
+		err = ioutil.WriteFile(MappingOutput, content, 0644)
+		if err != nil {
+			return errors.Wrap(err, "Error writing mapping file")
+		}
+
+		fmt.Printf("Mapping file written to %s\n", MappingOutput)
+
+		return nil
+	},
+}
+
+func init() {
+	MappingGenerateCmd.Flags().StringVar(&EnvFile, "env-file", "", "Read the variables from a .env file instead of the environment")
+	MappingGenerateCmd.Flags().StringVarP(&MappingOutput, "output", "o", "", "Write the mapping to a file instead of the standard output")
+	MappingGenerateCmd.Flags().StringVarP(&MappingFormat, "format", "f", "", "Mapping format [json, yaml] (default from the output file extension, or json)")
+	MappingGenerateCmd.Flags().BoolVarP(&Interactive, "interactive", "i", false, "Confirm each entry before writing the mapping")
+	MappingCmd.AddCommand(MappingGenerateCmd)
+}
+
+var (
+	secretName     = regexp.MustCompile(`(?i)(SECRET|PASSWORD|PASSWD|TOKEN|API_?KEY|PRIVATE_?KEY|CREDENTIAL|_KEY$|_PWD$|_PASS$)`)
+	accessKeyID    = regexp.MustCompile(`^(.*?)_?ACCESS_KEY_ID$`)
+	clientID       = regexp.MustCompile(`^(.*?)_?CLIENT_ID$`)
+	credentialPart = regexp.MustCompile(`^(.+?)_(USER|USERNAME|LOGIN|PASSWORD|PASSWD|PASS|PWD|HOST|HOSTNAME|SERVER)$`)
+)
+
+/**
+ * Groups variable names into the mapping blocks by their naming
+ * conventions. Names not recognized as secrets are left out.
+ */
+func generateMapping(names []string) dsmSdk.Mapping {
+	var mapping dsmSdk.Mapping
+
+	sort.Strings(names)
+
+	available := make(map[string]bool, len(names))
+	for _, name := range names {
+		if !strings.HasPrefix(name, "SENHASEGURA_") && isValidVariableName(name) {
+			available[name] = true
+		}
+	}
+
+	used := make(map[string]bool)
+	entryNames := make(map[string]bool)
+
+	uniqueName := func(name string) string {
+		candidate := name
+		for i := 2; entryNames[candidate]; i++ {
+			candidate = fmt.Sprintf("%s_%d", name, i)
+		}
+		entryNames[candidate] = true
+		return candidate
+	}
+
+	for _, name := range names {
+		if !available[name] {
+			continue
+		}
+
+		if match := accessKeyID.FindStringSubmatch(name); match != nil {
+			secret := strings.TrimSuffix(name, "ACCESS_KEY_ID") + "SECRET_ACCESS_KEY"
+			if !available[secret] {
+				continue
+			}
+
+			cloud := cloudType(match[1])
+			mapping.AccessKeys = append(mapping.AccessKeys, dsmSdk.AccessKeyMapping{
+				Name: uniqueName(entryName(match[1], cloud)),
+				Type: cloud,
+				Fields: dsmSdk.AccessKeyFields{
+					AccessKeyID:     name,
+					SecretAccessKey: secret,
+				},
+			})
+			used[name], used[secret] = true, true
+			continue
+		}
+
+		if match := clientID.FindStringSubmatch(name); match != nil && cloudType(match[1]) == "azure" {
+			secret := strings.TrimSuffix(name, "CLIENT_ID") + "CLIENT_SECRET"
+			if !available[secret] {
+				continue
+			}
+
+			mapping.AccessKeys = append(mapping.AccessKeys, dsmSdk.AccessKeyMapping{
+				Name: uniqueName(entryName(match[1], "azure")),
+				Type: "azure",
+				Fields: dsmSdk.AccessKeyFields{
+					AccessKeyID:     name,
+					SecretAccessKey: secret,
+				},
+			})
+			used[name], used[secret] = true, true
+		}
+	}
+
+	credentials := make(map[string]*dsmSdk.CredentialFields)
+	var prefixes []string
+
+	for _, name := range names {
+		if !available[name] || used[name] {
+			continue
+		}
+
+		match := credentialPart.FindStringSubmatch(name)
+		if match == nil {
+			continue
+		}
+
+		fields, ok := credentials[match[1]]
+		if !ok {
+			fields = &dsmSdk.CredentialFields{}
+			credentials[match[1]] = fields
+			prefixes = append(prefixes, match[1])
+		}
+
+		switch match[2] {
+		case "USER", "USERNAME", "LOGIN":
+			if fields.User == "" {
+				fields.User = name
+			}
+		case "HOST", "HOSTNAME", "SERVER":
+			if fields.Host == "" {
+				fields.Host = name
+			}
+		default:
+			if fields.Password == "" {
+				fields.Password = name
+			}
+		}
+	}
+
+	sort.Strings(prefixes)
+
+	for _, prefix := range prefixes {
+		fields := credentials[prefix]
+		if fields.User == "" || fields.Password == "" {
+			continue
+		}
+
+		mapping.Credentials = append(mapping.Credentials, dsmSdk.CredentialMapping{
+			Name:   uniqueName(prefix),
+			Fields: *fields,
+		})
+		used[fields.User], used[fields.Password], used[fields.Host] = true, true, true
+	}
+
+	var generic []string
+	for _, name := range names {
+		if available[name] && !used[name] && secretName.MatchString(name) {
+			generic = append(generic, name)
+		}
+	}
+
+	if len(generic) > 0 {
+		mapping.KeyValue = append(mapping.KeyValue, dsmSdk.KeyValueMapping{
+			Name:   uniqueName("GENERIC_VARIABLES"),
+			Fields: generic,
+		})
+	}
+
+	return mapping
+}
+
+/**
+ * Guesses the cloud of an access key pair from the words prefixing its
+ * names, defaulting to AWS
+ */
+func cloudType(prefix string) string {
+	for _, word := range strings.Split(strings.ToUpper(prefix), "_") {
+		switch word {
+		case "AZURE", "ARM":
+			return "azure"
+		case "GCP", "GCS", "GOOGLE":
+			return "gcp"
+		}
+	}
+
+	return "aws"
+}
+
+func entryName(prefix string, cloud string) string {
+	prefix = strings.Trim(prefix, "_")
+	if prefix == "" {
+		return strings.ToUpper(cloud)
+	}
+
+	return prefix
+}
+
+/**
+ * Asks whether each entry of the mapping should be kept, returning the
+ * mapping with the accepted entries only
+ */
+func confirmMapping(in *bufio.Reader, out io.Writer, mapping dsmSdk.Mapping) (dsmSdk.Mapping, error) {
+	var confirmed dsmSdk.Mapping
+
+	ask := func(description string) (bool, error) {
+		fmt.Fprintf(out, "Keep %s? [Y/n] ", description)
+
+		answer, err := in.ReadString('\n')
+		if err != nil && (err != io.EOF || answer == "") {
+			return false, errors.Wrap(err, "Error reading answer")
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "", "y", "yes":
+			return true, nil
+		}
+
+		return false, nil
+	}
+
+	for _, entry := range mapping.AccessKeys {
+		keep, err := ask(fmt.Sprintf("access key %s (%s) with %s and %s", entry.Name, entry.Type, entry.Fields.AccessKeyID, entry.Fields.SecretAccessKey))
+		if err != nil {
+			return confirmed, err
+		}
+		if keep {
+			confirmed.AccessKeys = append(confirmed.AccessKeys, entry)
+		}
+	}
+
+	for _, entry := range mapping.Credentials {
+		fields := []string{entry.Fields.User, entry.Fields.Password}
+		if entry.Fields.Host != "" {
+			fields = append(fields, entry.Fields.Host)
+		}
+
+		keep, err := ask(fmt.Sprintf("credential %s with %s", entry.Name, strings.Join(fields, ", ")))
+		if err != nil {
+			return confirmed, err
+		}
+		if keep {
+			confirmed.Credentials = append(confirmed.Credentials, entry)
+		}
+	}
+
+	for _, entry := range mapping.KeyValue {
+		var fields []string
+
+		for _, field := range entry.Fields {
+			keep, err := ask("key/value " + field)
+			if err != nil {
+				return confirmed, err
+			}
+			if keep {
+				fields = append(fields, field)
+			}
+		}
+
+		if len(fields) > 0 {
+			confirmed.KeyValue = append(confirmed.KeyValue, dsmSdk.KeyValueMapping{Name: entry.Name, Fields: fields})
+		}
+	}
+
+	return confirmed, nil
+}
+
+func encodeMapping(mapping dsmSdk.Mapping, format string) ([]byte, error) {
+	switch format {
+	case "json":
+		content, err := json.MarshalIndent(mapping, "", "  ")
+		return append(content, '\n'), err
+	case "yaml":
+		return yaml.Marshal(mapping)
+	}
+
+	return nil, errors.Errorf("Format '%s' is invalid, it must be json or yaml", format)
+}
+
+/**
+ * Reads the KEY=VALUE entries of a .env file
+ */
+func readEnvFile(path string) ([]string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error reading env file")
+	}
+
+	return parseEnv(string(content), path), nil
+}
+
+/**
+ * Parses KEY=VALUE lines, ignoring comments and the export keyword
+ */
+func parseEnv(content string, source string) []string {
+	var environ []string
+
+	for n, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 || !isValidVariableName(strings.TrimSpace(parts[0])) {
+			v("Skipping line %d of %s, it is not a variable assignment\n", n+1, source)
+			continue
+		}
+
+		environ = append(environ, strings.TrimSpace(parts[0])+"="+parts[1])
+	}
+
+	return environ
+}
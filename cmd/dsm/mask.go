@@ -0,0 +1,44 @@
+package dsm
+
+import (
+	"encoding/base64"
+	"strings"
+)
+
+// minMaskedLength keeps very short derived values, such as a "}" line of a
+// JSON secret, from being masked all over the logs.
+const minMaskedLength = 4
+
+/**
+ * Returns the values to hide from the CI logs: each secret value, each line
+ * of multi-line values and their base64 encodings
+ */
+func maskValues(vars []Variable) []string {
+	var values []string
+	seen := make(map[string]bool)
+
+	add := func(value string, derived bool) {
+		if value == "" || seen[value] || (derived && len(value) < minMaskedLength) {
+			return
+		}
+		seen[value] = true
+		values = append(values, value)
+	}
+
+	for _, variable := range vars {
+		value := variable.Value
+
+		add(value, false)
+		add(base64.StdEncoding.EncodeToString([]byte(value)), true)
+		add(base64.RawStdEncoding.EncodeToString([]byte(value)), true)
+		add(base64.URLEncoding.EncodeToString([]byte(value)), true)
+
+		if strings.ContainsAny(value, "\r\n") {
+			for _, line := range strings.Split(value, "\n") {
+				add(strings.TrimSpace(line), true)
+			}
+		}
+	}
+
+	return values
+}
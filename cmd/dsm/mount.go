@@ -0,0 +1,75 @@
+package dsm
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	dsmSdk "github.com/senhasegura/dsmcli/sdk/dsm"
+)
+
+var SecretsFolder string
+var SaveCredentials bool
+var Watch bool
+
+var MountCmd = &cobra.Command{
+	Use:   "mount",
+	Short: "Write the application secrets as a volume of files, like Kubernetes secret volumes.",
+	Long: `Write the application secrets as a volume of files, like Kubernetes secret volumes.
+
+Every key of a secret is written to SENHASEGURA_SECRETS_FOLDER/senhasegura/<identity>/<key>, as read only files in read only directories. The files are written to a new directory and swapped in atomically through the "..data" symlink, so applications never read a partial update, and can watch "..data" to notice one. "..data/..metadata" holds the time of the update and the version of each secret.
+
+Without --watch the secrets are written once, for example from an init container. With --watch they are refreshed as their TTL expires, for example from a sidecar.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cmd.Flags().Changed("folder") {
+			viper.Set("SENHASEGURA_SECRETS_FOLDER", SecretsFolder)
+		}
+
+		cmd.SilenceUsage = true
+
+		ctx := cmd.Context()
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		mounted := false
+
+		agent := &agent{once: !Watch}
+		agent.apply = func(secrets dsmSdk.Secrets) error {
+			if SaveCredentials && !mounted {
+				// The credentials do not change between refreshes
+				err := agent.appResponse.SaveToFile()
+				if err != nil {
+					return err
+				}
+			}
+
+			err := secrets.SaveToFile()
+			if err != nil {
+				return err
+			}
+
+			mounted = true
+			return nil
+		}
+
+		stopHealth, err := agent.serveHealth()
+		if err != nil {
+			return err
+		}
+		defer stopHealth()
+
+		return agent.run(cmd, ctx)
+	},
+}
+
+func init() {
+	applicationFlags(MountCmd)
+	MountCmd.Flags().BoolVarP(&Verbose, "verbose", "v", false, "Verbose mode")
+	MountCmd.Flags().StringVar(&SecretsFolder, "folder", "", "Folder to write the secrets to (default is SENHASEGURA_SECRETS_FOLDER or /var/run/secrets)")
+	MountCmd.Flags().BoolVar(&SaveCredentials, "credentials", false, "Also write the application credentials to senhasegura/iso")
+	MountCmd.Flags().BoolVar(&Watch, "watch", false, "Keep refreshing the secrets as their TTL expires")
+	MountCmd.Flags().StringVar(&HealthAddr, "health-addr", "", "Address serving /healthz for liveness probes with --watch, e.g. :8080")
+}
@@ -0,0 +1,57 @@
+package dsm
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+/**
+ * Writes value as JSON or YAML. YAML is produced from the JSON encoding,
+ * so both formats use the same attribute names.
+ */
+func writeOutput(w io.Writer, format string, value interface{}) error {
+	content, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		_, err = w.Write(append(content, '\n'))
+		return err
+	case "yaml":
+		var generic interface{}
+
+		err = json.Unmarshal(content, &generic)
+		if err != nil {
+			return err
+		}
+
+		content, err = yaml.Marshal(generic)
+		if err != nil {
+			return err
+		}
+
+		_, err = w.Write(content)
+		return err
+	}
+
+	return errors.Errorf("Output format '%s' is invalid", format)
+}
+
+/**
+ * Rejects output formats not in the given list before any request is made
+ */
+func checkOutputFormat(format string, formats ...string) error {
+	for _, f := range formats {
+		if format == f {
+			return nil
+		}
+	}
+
+	return errors.Errorf("Output format '%s' is invalid, it must be one of the following values: %s", format, strings.Join(formats, ", "))
+}
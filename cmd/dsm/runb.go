@@ -16,28 +16,33 @@ limitations under the License.
 package dsm
 
 import (
+	"bytes"
+	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
-	"net/http"
-	"net/url"
+	"io/ioutil"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
 
 	dsmSdk "github.com/senhasegura/dsmcli/sdk/dsm"
 	isoSdk "github.com/senhasegura/dsmcli/sdk/iso"
 )
 
-var kv map[string]string
-
 var Verbose bool
 var ToolName string
 var Environment string
 var System string
 var ApplicationName string
+var NoEnvUpload bool
+var DryRun bool
+var StrictMapping bool
 
 var RunbCmd = &cobra.Command{
 	Use:   "runb",
@@ -48,32 +53,67 @@ var RunbCmd = &cobra.Command{
 			return errors.Errorf("SENHASEGURA_DISABLE_RUNB is set to true. Plugin is disabled.")
 		}
 
-		client, appClient, err := registerApplication()
+		injector, err := GetInjector(ToolName)
 		if err != nil {
 			return err
 		}
 
-		envVars := loadEnvVars()
-		mapVars := loadMapVars()
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		if DryRun {
+			secrets, found, err := findSecrets(ctx)
+			if err != nil {
+				return err
+			}
 
-		varClient := dsmSdk.NewVariableClient(&client)
+			return printPlan(os.Stdout, injector, secretVariables(secrets), found)
+		}
 
-		_, err = varClient.Register(envVars, mapVars)
+		appClient, err := registerApplication(ctx)
 		if err != nil {
-			return errors.Errorf("Error when posting variables in senhasegura: " + err.Error())
+			return err
 		}
 
-		secrets, err := appClient.GetSecrets()
+		envVars, mapVars := "", ""
+		if NoEnvUpload {
+			// The mapping only refers to variables by name, without their
+			// values senhasegura cannot register any secret from it
+			v("Environment variables and mapping upload disabled\n")
+		} else {
+			envVars, err = loadEnvVars()
+			if err != nil {
+				return err
+			}
+
+			mapVars, err = loadMapVars()
+			if err != nil {
+				return err
+			}
+		}
+
+		if envVars != "" || mapVars != "" {
+			varClient := dsmSdk.NewVariableClient(appClient.GetClient())
+
+			_, err = varClient.RegisterContext(ctx, envVars, mapVars)
+			if err != nil {
+				return errors.Wrap(err, "Error when posting variables in senhasegura")
+			}
+		}
+
+		secrets, err := appClient.GetSecretsContext(ctx)
 		if err != nil {
 			return err
 		}
 
-		err = injectEnvironmentVariables(secrets)
+		vars := secretVariables(secrets)
+
+		err = injectEnvironmentVariables(ctx, injector, vars)
 		if err != nil {
 			return err
 		}
 
-		return deleteCICDVariables()
+		return deleteCICDVariables(ctx, injector, vars)
 	},
 }
 
@@ -82,7 +122,10 @@ func init() {
 	RunbCmd.Flags().StringVarP(&ApplicationName, "application", "a", "", "Application name (required)")
 	RunbCmd.Flags().StringVarP(&System, "system", "s", "", "Application system (required)")
 	RunbCmd.Flags().StringVarP(&Environment, "environment", "e", "", "Application environment (required)")
-	RunbCmd.Flags().StringVarP(&ToolName, "tool", "t", "linux", "Tool name [github, azure-devops, bamboo, bitbucket, circleci, teamcity, linux]")
+	RunbCmd.Flags().StringVarP(&ToolName, "tool", "t", "linux", "Tool name ["+strings.Join(InjectorNames(), ", ")+"]")
+	RunbCmd.Flags().BoolVar(&NoEnvUpload, "no-env-upload", false, "Do not send the environment variables, nor the mapping file, to senhasegura")
+	RunbCmd.Flags().BoolVar(&StrictMapping, "strict-mapping", false, "Fail when the mapping file does not follow the schema, instead of only warning")
+	RunbCmd.Flags().BoolVar(&DryRun, "dry-run", false, "Print what would be sent and written, without sending or writing anything")
 	RunbCmd.MarkFlagRequired("application")
 	RunbCmd.MarkFlagRequired("system")
 	RunbCmd.MarkFlagRequired("environment")
@@ -92,94 +135,62 @@ func isDisabled() bool {
 	return viper.GetBool("SENHASEGURA_DISABLE_RUNB")
 }
 
-func injectEnvironmentVariables(secrets []dsmSdk.Secret) error {
-	switch ToolName {
-	case "github":
-		return injectGithub(secrets)
-	case "azure-devops":
-		return injectAzureDevops(secrets)
-	case "bamboo":
-		return injectBamboo(secrets)
-	case "bitbucket":
-		return injectBitbucket(secrets)
-	case "circleci":
-		return injectCircleci(secrets)
-	case "teamcity":
-		return injectTeamcity(secrets)
-	case "linux":
-		return injectLinux(secrets)
-
-	default:
-		return errors.Errorf("Tool '%s' is invalid, it must be one of the following values: github, azure-devops, bamboo, bitbucket, circleci, teamcity or linux", ToolName)
-	}
-}
-
-func injectGithub(secrets []dsmSdk.Secret) error {
-	return inject(secrets, "echo '%s=%s' >> $GITHUB_ENV\n")
-}
-
-func injectAzureDevops(secrets []dsmSdk.Secret) error {
-	return inject(secrets, "echo '##vso[task.setvariable variable=%s;issecret=true;]%s'\n")
-}
-
-func injectBamboo(secrets []dsmSdk.Secret) error {
-	return inject(secrets, "(%s)=(.[%s])\n")
-}
-
-func injectBitbucket(secrets []dsmSdk.Secret) error {
-	return inject(secrets, "export (%s)=\"(.[%s])\"\n")
-}
-
-func injectCircleci(secrets []dsmSdk.Secret) error {
-	return inject(secrets, "echo '\"'\"'export (%s)=\"(.[%s])\"'\"'\"' >> $BASH_ENV\n")
-}
-
-func injectTeamcity(secrets []dsmSdk.Secret) error {
-	return inject(secrets, "echo '\"'\"'##teamcity[setParameter name=\"(%s)\" value=\"(.[%s])\"]'\"'\"'\"\n")
-}
-
-func injectLinux(secrets []dsmSdk.Secret) error {
-	return inject(secrets, "declare -x %s='%s'\n")
-}
-
-func inject(secrets []dsmSdk.Secret, format string) error {
+func injectEnvironmentVariables(ctx context.Context, injector Injector, vars []Variable) error {
 	v("Injecting secrets!\n")
 
-	kv = convertJSONToKV(secrets)
-
-	if len(kv) == 0 {
+	if len(vars) == 0 {
 		v("No secrets to be injected!\n")
 		return nil
 	}
 
-	secretsFile := viper.GetString("SENHASEGURA_SECRETS_FILE")
-	if secretsFile == "" {
-		secretsFile = ".runb.vars"
-	}
-
-	file, err := os.OpenFile(secretsFile, os.O_CREATE|os.O_RDWR, 0660)
+	err := injector.Mask(os.Stdout, maskValues(vars))
 	if err != nil {
 		return err
 	}
 
-	for key, value := range kv {
-		v("Injecting secret into %s: %s.....", secretsFile, key)
+	err = injector.Inject(ctx, vars)
 
-		_, err = file.WriteString(fmt.Sprintf(format, key, value))
-		if err != nil {
-			return err
+	// Files written before a failure must be cleaned up as well
+	if len(injectedFiles) > 0 || len(createdVariables) > 0 {
+		if manifestErr := writeManifest(ToolName, vars); manifestErr != nil && err == nil {
+			err = manifestErr
 		}
-
-		v("Success!\n")
 	}
 
-	file.Close()
+	if err != nil {
+		return err
+	}
 
 	v("Secrets injected!\n")
 
 	return nil
 }
 
+/**
+ * Flattens the secrets into variables sorted by key, skipping keys that
+ * cannot be used as variable names
+ */
+func secretVariables(secrets []dsmSdk.Secret) []Variable {
+	kv := convertJSONToKV(secrets)
+
+	keys := make([]string, 0, len(kv))
+	for key := range kv {
+		if !isValidVariableName(key) {
+			v("Skipping secret '%s', it is not a valid variable name\n", key)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	vars := make([]Variable, len(keys))
+	for i, key := range keys {
+		vars[i] = Variable{Key: key, Value: kv[key]}
+	}
+
+	return vars
+}
+
 func convertJSONToKV(secrets []dsmSdk.Secret) map[string]string {
 	kv := make(map[string]string)
 
@@ -195,44 +206,17 @@ func convertJSONToKV(secrets []dsmSdk.Secret) map[string]string {
 	return kv
 }
 
-func deleteCICDVariables() error {
+func deleteCICDVariables(ctx context.Context, injector Injector, vars []Variable) error {
 	v("Deleting %s variables...\n", ToolName)
 
-	if len(kv) == 0 {
+	if len(vars) == 0 {
 		v("No variables to be deleted!\n")
 		return nil
 	}
 
-	switch ToolName {
-	case "gitlab":
-		err := deleteGitLabVars()
-		if err != nil {
-			return err
-		}
-
-	case "github":
-		v("Is not possible to delete %s variables!\n", ToolName)
-
-	case "azure-devops":
-		v("Is not possible to delete %s variables!\n", ToolName)
-
-	case "bamboo":
-		v("Is not possible to delete %s variables!\n", ToolName)
-
-	case "bitbucket":
-		v("Is not possible to delete %s variables!\n", ToolName)
-
-	case "circleci":
-		v("Is not possible to delete %s variables!\n", ToolName)
-
-	case "teamcity":
-		v("Is not possible to delete %s variables!\n", ToolName)
-
-	case "linux":
-		v("Is not possible to delete %s variables!\n", ToolName)
-
-	default:
-		return errors.Errorf("Tool '%s' is invalid, it must be one of the following values: github, azure-devops, bamboo, bitbucket, circleci, teamcity or linux", ToolName)
+	err := injector.Cleanup(ctx, vars)
+	if err != nil {
+		return err
 	}
 
 	v("Finish\n")
@@ -240,84 +224,154 @@ func deleteCICDVariables() error {
 	return nil
 }
 
-func deleteGitLabVars() error {
-	if !IsSet("GITLAB_ACCESS_TOKEN", "CI_API_V4_URL", "CI_PROJECT_ID") {
-		v("Deletion failed\n")
-		v("To delete github variables, you need to define the configs GITLAB_ACCESS_TOKEN, CI_API_V4_URL and CI_PROJECT_ID\n")
-		return nil
+/**
+ * Looks the application up without registering it, returning its secrets
+ * and whether it exists
+ */
+func findSecrets(ctx context.Context) (dsmSdk.Secrets, bool, error) {
+	client, err := newClient()
+	if err != nil {
+		return nil, false, err
 	}
 
-	if len(kv) == 0 {
-		v("Deletion failed\n")
-		v("Has no credentials to exclude variables on '%s' tool ...\n", ToolName)
-		return nil
+	appClient, err := dsmSdk.NewApplicationClient(&client, ApplicationName, Environment, System)
+	if err != nil {
+		return nil, false, err
 	}
 
-	headers := map[string]string{"PRIVATE-TOKEN": viper.GetString("GITLAB_ACCESS_TOKEN")}
+	appResponse, err := appClient.FindContext(ctx)
+	if errors.Is(err, isoSdk.ErrNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
 
-	for key := range kv {
-		v("Deleting %s variable\n", key)
-
-		resource := fmt.Sprintf(
-			"%s/projects/%s/variables/%s",
-			viper.GetString("CI_API_V4_URL"),
-			viper.GetString("CI_PROJECT_ID"),
-			key,
-		)
-
-		_, err := isoSdk.DoRequest(
-			viper.GetString("GITLAB_ACCESS_TOKEN"),
-			resource,
-			url.Values{},
-			headers,
-			http.MethodDelete,
-		)
+	return appResponse.Application.Secrets, true, nil
+}
 
-		if err != nil {
-			v("Failed trying to delete '%s' variable\n", err.Error())
-			continue
-		}
+/**
+ * Registers the application and returns a client authenticating with its
+ * credentials. The same iso client is shared by every following request.
+ */
+func registerApplication(ctx context.Context) (dsmSdk.ApplicationClient, error) {
+	appClient, _, err := registerApplicationResponse(ctx)
+	return appClient, err
+}
 
-		v("Deleted\n")
+/**
+ * Registers the application like registerApplication, also returning the
+ * response holding its credentials
+ */
+func registerApplicationResponse(ctx context.Context) (dsmSdk.ApplicationClient, dsmSdk.ApplicationResponse, error) {
+	client, err := newClient()
+	if err != nil {
+		return dsmSdk.ApplicationClient{}, dsmSdk.ApplicationResponse{}, err
 	}
-	return nil
-}
 
-func registerApplication() (isoSdk.Client, dsmSdk.ApplicationClient, error) {
-	client, _ := isoSdk.NewClient(getConfig())
-	appClient := dsmSdk.NewApplicationClient(&client, ApplicationName, Environment, System)
+	appClient, err := dsmSdk.NewApplicationClient(&client, ApplicationName, Environment, System)
+	if err != nil {
+		return appClient, dsmSdk.ApplicationResponse{}, err
+	}
 
-	appResponse, err := appClient.Register()
+	appResponse, err := appClient.RegisterContext(ctx)
 	if err != nil {
-		return client, appClient, err
+		return appClient, appResponse, err
 	}
 
-	client.DefineNewCredentials(appResponse.ID, appResponse.Signature)
+	err = appClient.DefineCredentialsByApplication(appResponse)
+	if err != nil {
+		return appClient, appResponse, err
+	}
 
-	return client, appClient, nil
+	return appClient, appResponse, nil
 }
 
-func loadEnvVars() string {
-	envVars := strings.Join(os.Environ(), "\n")
+func loadEnvVars() (string, error) {
+	environ, err := filterEnv(os.Environ())
+	if err != nil {
+		return "", err
+	}
+
+	if len(environ) == 0 {
+		v("No environment variables to be sent!\n")
+		return "", nil
+	}
+
+	v("Environment variables to be sent:\n")
+	for _, name := range envNames(environ) {
+		v("  %s\n", name)
+	}
+
+	envVars := strings.Join(environ, "\n")
 	envVars = base64.StdEncoding.EncodeToString([]byte(envVars))
 	envVars = replaceSpecials(envVars)
-	return envVars
+	return envVars, nil
 }
 
-func loadMapVars() string {
+/**
+ * Reads the mapping file, sending it to senhasegura as JSON whatever format
+ * it was written in. Problems found in the file are only reported, unless
+ * --strict-mapping is set, as senhasegura has the final word on it.
+ */
+func loadMapVars() (string, error) {
 	if !IsSet("SENHASEGURA_MAPPING_FILE") {
 		v("Mapping file not found, proceeding...\n")
-	} else {
-		v("Using mapping file: %s\n", viper.GetString("SENHASEGURA_MAPPING_FILE"))
+		return "", nil
+	}
+
+	path := viper.GetString("SENHASEGURA_MAPPING_FILE")
+	v("Using mapping file: %s\n", path)
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", errors.Wrap(err, "Error reading mapping file")
+	}
+
+	mapping, err := dsmSdk.ParseMapping(content)
+	if err == nil {
+		err = mapping.Validate()
 	}
 
-	content, err := os.ReadFile(viper.GetString("SENHASEGURA_MAPPING_FILE"))
 	if err != nil {
-		return ""
+		if StrictMapping {
+			return "", errors.Wrapf(err, "Mapping file '%s'", path)
+		}
+
+		fmt.Fprintf(os.Stderr, "Warning: mapping file '%s': %v, sending it anyway, run 'dsm mapping validate' for details\n", path, err)
+
+		content, err = mappingJSON(content)
+		if err != nil {
+			return "", errors.Wrapf(err, "Mapping file '%s'", path)
+		}
+	} else {
+		content, err = json.Marshal(mapping)
+		if err != nil {
+			return "", err
+		}
 	}
 
-	mapVars := string(content)
-	mapVars = base64.StdEncoding.EncodeToString([]byte(mapVars))
+	mapVars := base64.StdEncoding.EncodeToString(content)
 	mapVars = replaceSpecials(mapVars)
-	return mapVars
+	return mapVars, nil
+}
+
+/**
+ * Converts a mapping that does not follow the schema to JSON, keeping JSON
+ * files as they are and ignoring unknown YAML attributes
+ */
+func mappingJSON(content []byte) ([]byte, error) {
+	trimmed := bytes.TrimSpace(content)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		return trimmed, nil
+	}
+
+	var mapping dsmSdk.Mapping
+
+	err := yaml.Unmarshal(trimmed, &mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(mapping)
 }
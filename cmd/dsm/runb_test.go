@@ -0,0 +1,87 @@
+package dsm
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+/**
+ * Writes a mapping file and points SENHASEGURA_MAPPING_FILE to it
+ */
+func mappingFile(t *testing.T, name string, content string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	err := ioutil.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setConfig(t, "SENHASEGURA_MAPPING_FILE", path)
+}
+
+/**
+ * Returns the JSON mapping encoded by loadMapVars
+ */
+func decodeMapVars(t *testing.T, mapVars string) string {
+	t.Helper()
+
+	replacer := strings.NewReplacer("-", "+", "_", "/", ",", "=")
+	content, err := base64.StdEncoding.DecodeString(replacer.Replace(mapVars))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(content)
+}
+
+func TestLoadMapVarsSendsInvalidMappings(t *testing.T) {
+	const mapping = `{"key_value":[{"name":"GENERIC","fields":["TOKEN"]}],"custom":true}`
+	mappingFile(t, "mapping.json", mapping)
+
+	mapVars, err := loadMapVars()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := decodeMapVars(t, mapVars); got != mapping {
+		t.Errorf("sent %s, want the file as it is", got)
+	}
+}
+
+func TestLoadMapVarsStrict(t *testing.T) {
+	mappingFile(t, "mapping.json", `{"key_value":[{"name":"GENERIC","fields":["TOKEN"]}],"custom":true}`)
+
+	StrictMapping = true
+	t.Cleanup(func() { StrictMapping = false })
+
+	_, err := loadMapVars()
+	if err == nil {
+		t.Fatal("invalid mapping accepted with --strict-mapping")
+	}
+}
+
+func TestLoadMapVarsConvertsYAML(t *testing.T) {
+	mappingFile(t, "mapping.yaml", `
+access_keys:
+  - name: CLOUD
+    type: AWS
+    fields:
+      access_key_id: KEY_ID
+      secret_access_key: SECRET_KEY
+    region: us-east-1
+`)
+
+	mapVars, err := loadMapVars()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"access_keys":[{"name":"CLOUD","type":"AWS","fields":{"access_key_id":"KEY_ID","secret_access_key":"SECRET_KEY"}}]}`
+	if got := decodeMapVars(t, mapVars); got != want {
+		t.Errorf("sent %s, want %s", got, want)
+	}
+}
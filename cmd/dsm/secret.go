@@ -0,0 +1,137 @@
+package dsm
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	dsmSdk "github.com/senhasegura/dsmcli/sdk/dsm"
+	isoSdk "github.com/senhasegura/dsmcli/sdk/iso"
+)
+
+var GetOutput string
+
+var SecretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "Read, list, create, update and delete secrets.",
+	Long:  `Read, list, create, update and delete secrets.`,
+}
+
+var SecretGetCmd = &cobra.Command{
+	Use:   "get <identity>[/<key>]",
+	Short: "Print a secret, or a single key of a secret.",
+	Long: `Print a secret, or a single key of a secret.
+
+The secret is read from the application without sending the environment to senhasegura or writing any file. With the raw output, a key is printed as is, without a trailing line break, and a whole secret as KEY=value lines. The command exits with code 3 when the secret or the key does not exist.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := checkOutputFormat(GetOutput, "raw", "json", "yaml")
+		if err != nil {
+			return err
+		}
+
+		identity, key := args[0], ""
+		if i := strings.LastIndex(identity, "/"); i >= 0 {
+			identity, key = identity[:i], identity[i+1:]
+		}
+
+		cmd.SilenceUsage = true
+
+		secrets, err := fetchSecrets(cmd)
+		if err != nil {
+			return err
+		}
+
+		secret, ok := findSecret(secrets, identity)
+		if !ok {
+			return errors.Wrapf(isoSdk.ErrNotFound, "Secret '%s'", identity)
+		}
+
+		data := secretData(secret)
+
+		if key == "" {
+			if GetOutput == "raw" {
+				for _, k := range sortedKeys(data) {
+					fmt.Printf("%s=%s\n", k, data[k])
+				}
+				return nil
+			}
+
+			return writeOutput(os.Stdout, GetOutput, secret)
+		}
+
+		value, ok := data[key]
+		if !ok {
+			return errors.Wrapf(isoSdk.ErrNotFound, "Key '%s' of secret '%s'", key, identity)
+		}
+
+		if GetOutput == "raw" {
+			_, err = fmt.Print(value)
+			return err
+		}
+
+		return writeOutput(os.Stdout, GetOutput, map[string]string{key: value})
+	},
+}
+
+func init() {
+	SecretCmd.PersistentFlags().BoolVarP(&Verbose, "verbose", "v", false, "Verbose mode")
+
+	applicationFlags(SecretGetCmd)
+	SecretGetCmd.Flags().StringVarP(&GetOutput, "output", "o", "raw", "Output format [raw, json, yaml]")
+	SecretCmd.AddCommand(SecretGetCmd)
+}
+
+/**
+ * Adds the required flags identifying the application to a command
+ */
+func applicationFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&ApplicationName, "application", "a", "", "Application name (required)")
+	cmd.Flags().StringVarP(&System, "system", "s", "", "Application system (required)")
+	cmd.Flags().StringVarP(&Environment, "environment", "e", "", "Application environment (required)")
+	cmd.MarkFlagRequired("application")
+	cmd.MarkFlagRequired("system")
+	cmd.MarkFlagRequired("environment")
+}
+
+/**
+ * Returns the secret of the application with the given identity
+ */
+func findSecret(secrets []dsmSdk.Secret, identity string) (dsmSdk.Secret, bool) {
+	for _, secret := range secrets {
+		if secret.Identity == identity {
+			return secret, true
+		}
+	}
+
+	return dsmSdk.Secret{}, false
+}
+
+/**
+ * Merges the data entries of a secret into a single map
+ */
+func secretData(secret dsmSdk.Secret) map[string]string {
+	data := make(map[string]string)
+
+	for _, entry := range secret.Data {
+		for key, value := range entry {
+			data[key] = value
+		}
+	}
+
+	return data
+}
+
+func sortedKeys(data map[string]string) []string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
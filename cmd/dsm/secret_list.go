@@ -0,0 +1,147 @@
+package dsm
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	dsmSdk "github.com/senhasegura/dsmcli/sdk/dsm"
+)
+
+var ListOutput string
+var EngineFilter string
+var IdentityFilter string
+var ExpiresWithin time.Duration
+
+// secretSummary describes a secret without any of its values.
+type secretSummary struct {
+	SecretID       string   `json:"secret_id"`
+	SecretName     string   `json:"secret_name"`
+	Identity       string   `json:"identity"`
+	Version        string   `json:"version"`
+	Engine         string   `json:"engine"`
+	ExpirationDate string   `json:"expiration_date"`
+	Keys           []string `json:"keys"`
+}
+
+// expirationLayouts are the formats accepted for the expiration date.
+var expirationLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+var SecretListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the secrets of an application, without their values.",
+	Long: `List the secrets of an application, without their values.
+
+Secrets can be filtered by engine, by identity using a glob pattern such as 'db-*', and by expiration with --expires-within, which keeps the secrets expiring within the given duration, including those already expired.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := checkOutputFormat(ListOutput, "table", "json", "yaml")
+		if err != nil {
+			return err
+		}
+
+		if _, err = path.Match(IdentityFilter, ""); err != nil {
+			return errors.Errorf("Invalid identity pattern '%s'", IdentityFilter)
+		}
+
+		cmd.SilenceUsage = true
+
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		appClient, err := registerApplication(ctx)
+		if err != nil {
+			return err
+		}
+
+		app, err := appClient.GetApplicationContext(ctx)
+		if err != nil {
+			return err
+		}
+
+		summaries := []secretSummary{}
+		for _, secret := range app.Application.Secrets {
+			if keepSecret(secret, time.Now()) {
+				summaries = append(summaries, summarizeSecret(secret))
+			}
+		}
+
+		if ListOutput != "table" {
+			return writeOutput(os.Stdout, ListOutput, summaries)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tIDENTITY\tVERSION\tENGINE\tEXPIRATION\tKEYS")
+		for _, s := range summaries {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", s.SecretID, s.SecretName, s.Identity, s.Version, s.Engine, s.ExpirationDate, strings.Join(s.Keys, ","))
+		}
+
+		return w.Flush()
+	},
+}
+
+func init() {
+	applicationFlags(SecretListCmd)
+	SecretListCmd.Flags().StringVarP(&ListOutput, "output", "o", "table", "Output format [table, json, yaml]")
+	SecretListCmd.Flags().StringVar(&EngineFilter, "engine", "", "Only list secrets of this engine")
+	SecretListCmd.Flags().StringVar(&IdentityFilter, "identity", "*", "Only list secrets whose identity matches this glob pattern")
+	SecretListCmd.Flags().DurationVar(&ExpiresWithin, "expires-within", 0, "Only list secrets expiring within this duration, e.g. 720h")
+	SecretCmd.AddCommand(SecretListCmd)
+}
+
+/**
+ * Reports whether a secret passes the engine, identity and expiry filters
+ */
+func keepSecret(secret dsmSdk.Secret, now time.Time) bool {
+	if EngineFilter != "" && !strings.EqualFold(secret.Engine, EngineFilter) {
+		return false
+	}
+
+	if matched, _ := path.Match(IdentityFilter, secret.Identity); !matched {
+		return false
+	}
+
+	if ExpiresWithin > 0 {
+		expiration, ok := parseExpiration(secret.ExpirationDate)
+		if !ok {
+			v("Skipping secret '%s', its expiration date '%s' is unknown\n", secret.Identity, secret.ExpirationDate)
+			return false
+		}
+
+		return expiration.Before(now.Add(ExpiresWithin))
+	}
+
+	return true
+}
+
+func parseExpiration(value string) (time.Time, bool) {
+	for _, layout := range expirationLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func summarizeSecret(secret dsmSdk.Secret) secretSummary {
+	return secretSummary{
+		SecretID:       secret.SecretID,
+		SecretName:     secret.SecretName,
+		Identity:       secret.Identity,
+		Version:        secret.Version,
+		Engine:         secret.Engine,
+		ExpirationDate: secret.ExpirationDate,
+		Keys:           sortedKeys(secretData(secret)),
+	}
+}
@@ -0,0 +1,228 @@
+package dsm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	dsmSdk "github.com/senhasegura/dsmcli/sdk/dsm"
+)
+
+var SecretData []string
+var SecretFile string
+var ExpectedVersion string
+
+var SecretPutCmd = &cobra.Command{
+	Use:   "put <identity>",
+	Short: "Create a secret or replace its data, printing its new version.",
+	Long: `Create a secret or replace its data, printing its new version.
+
+The data is read from --data KEY=VALUE flags, and from a file or the standard input with --file, holding either a JSON object or KEY=VALUE lines. Values are kept exactly as written, unless wrapped in double quotes, unescaped like Go strings, or in single quotes, taken literally, and any malformed line is an error. Flags take precedence over the file. Values given as flags are visible to other users of the machine, prefer the standard input for sensitive values.
+
+With --expected-version the secret is only changed when its current version matches, use 0 to only create a secret that does not exist yet. The command exits with code 6 otherwise. DSM CLI compares the version before writing, which cannot stop two concurrent writers that both saw it. Only senhasegura enforcing the expected version on the write itself rejects the second one.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := secretInput()
+		if err != nil {
+			return err
+		}
+
+		cmd.SilenceUsage = true
+
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+
+		secretClient := dsmSdk.NewSecretClient(&client)
+
+		secret, err := secretClient.PutContext(ctx, args[0], data, ExpectedVersion)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(secret.Version)
+
+		return nil
+	},
+}
+
+var SecretDeleteCmd = &cobra.Command{
+	Use:   "delete <identity>",
+	Short: "Delete a secret.",
+	Long: `Delete a secret.
+
+With --expected-version the secret is only deleted when its current version matches, the command exits with code 6 otherwise. Like put, the comparison made by DSM CLI is best-effort under concurrent changes.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+
+		secretClient := dsmSdk.NewSecretClient(&client)
+
+		err = secretClient.DeleteContext(ctx, args[0], ExpectedVersion)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Secret '%s' deleted\n", args[0])
+
+		return nil
+	},
+}
+
+func init() {
+	SecretPutCmd.Flags().StringArrayVarP(&SecretData, "data", "d", nil, "Secret entry as KEY=VALUE, may be repeated")
+	SecretPutCmd.Flags().StringVarP(&SecretFile, "file", "f", "", "Read the secret data from a file, or from the standard input with -")
+	SecretPutCmd.Flags().StringVar(&ExpectedVersion, "expected-version", "", "Only change the secret if this is its current version")
+	SecretCmd.AddCommand(SecretPutCmd)
+
+	SecretDeleteCmd.Flags().StringVar(&ExpectedVersion, "expected-version", "", "Only delete the secret if this is its current version")
+	SecretCmd.AddCommand(SecretDeleteCmd)
+}
+
+/**
+ * Collects the secret data from the --file and --data flags
+ */
+func secretInput() (map[string]string, error) {
+	data := make(map[string]string)
+
+	if SecretFile != "" {
+		var content []byte
+		var err error
+
+		if SecretFile == "-" {
+			content, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			content, err = ioutil.ReadFile(SecretFile)
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "Error reading secret data")
+		}
+
+		data, err = parseSecretData(content)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, entry := range SecretData {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.Errorf("Invalid secret entry, it must be KEY=VALUE")
+		}
+
+		data[parts[0]] = parts[1]
+	}
+
+	if len(data) == 0 {
+		return nil, errors.Errorf("Provide the secret data with --data or --file")
+	}
+
+	return data, nil
+}
+
+/**
+ * Parses secret data written as a JSON object or as KEY=VALUE lines
+ */
+func parseSecretData(content []byte) (map[string]string, error) {
+	data := make(map[string]string)
+
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("{")) {
+		err := json.Unmarshal(content, &data)
+		if err != nil {
+			return nil, errors.Wrap(err, "Invalid secret data")
+		}
+
+		return data, nil
+	}
+
+	return parseSecretLines(string(content))
+}
+
+/**
+ * Parses KEY=VALUE lines, skipping blank lines and comments. Values are
+ * kept exactly as written, unless they are wrapped in double quotes,
+ * unescaped like Go strings, or in single quotes, taken literally. Any
+ * other line is an error, as a secret must never be silently truncated.
+ */
+func parseSecretLines(content string) (map[string]string, error) {
+	data := make(map[string]string)
+	lines := make(map[string]int)
+
+	for n, line := range strings.Split(content, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || strings.ContainsAny(parts[0], " \t") {
+			return nil, errors.Errorf("Invalid secret data on line %d, it must be KEY=VALUE", n+1)
+		}
+
+		key, value := parts[0], parts[1]
+
+		if previous, ok := lines[key]; ok {
+			return nil, errors.Errorf("Invalid secret data on line %d, '%s' is already set on line %d", n+1, key, previous)
+		}
+
+		value, err := unquoteSecretValue(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid secret data on line %d", n+1)
+		}
+
+		data[key] = value
+		lines[key] = n + 1
+	}
+
+	return data, nil
+}
+
+/**
+ * Removes the quotes around a value, leaving unquoted values untouched
+ */
+func unquoteSecretValue(value string) (string, error) {
+	if value == "" || (value[0] != '"' && value[0] != '\'') {
+		return value, nil
+	}
+
+	if len(value) < 2 || value[len(value)-1] != value[0] {
+		return "", errors.Errorf("unterminated quoted value")
+	}
+
+	if value[0] == '\'' {
+		inner := value[1 : len(value)-1]
+		if strings.Contains(inner, "'") {
+			return "", errors.Errorf("single quoted values cannot contain single quotes")
+		}
+
+		return inner, nil
+	}
+
+	unquoted, err := strconv.Unquote(value)
+	if err != nil {
+		return "", errors.Errorf("invalid double quoted value")
+	}
+
+	return unquoted, nil
+}
@@ -0,0 +1,62 @@
+package dsm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSecretLines(t *testing.T) {
+	content := strings.Join([]string{
+		"# database credentials",
+		"",
+		"DB_USER=admin",
+		"DB_PASSWORD= p@ss=word ",
+		`DB_QUOTED="line one\nline two"`,
+		`DB_LITERAL='"$HOME"\n'`,
+		"DB_EMPTY=",
+		"DB_WINDOWS=crlf\r",
+	}, "\n")
+
+	data, err := parseSecretData([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"DB_USER":     "admin",
+		"DB_PASSWORD": " p@ss=word ",
+		"DB_QUOTED":   "line one\nline two",
+		"DB_LITERAL":  `"$HOME"\n`,
+		"DB_EMPTY":    "",
+		"DB_WINDOWS":  "crlf",
+	}
+	if !reflect.DeepEqual(data, want) {
+		t.Errorf("data = %q, want %q", data, want)
+	}
+}
+
+func TestParseSecretLinesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"no separator", "DB_USER=admin\nDB_PASSWORD", "line 2"},
+		{"empty key", "=value", "line 1"},
+		{"export", "export DB_USER=admin", "line 1"},
+		{"duplicate", "DB_USER=admin\n\nDB_USER=root", "line 3, 'DB_USER' is already set on line 1"},
+		{"unterminated", `DB_PASSWORD="secret`, "line 1"},
+		{"bad escape", `DB_PASSWORD="\q"`, "line 1"},
+		{"single quote inside", `DB_PASSWORD='it's'`, "line 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseSecretData([]byte(tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
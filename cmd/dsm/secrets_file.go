@@ -0,0 +1,124 @@
+package dsm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+func secretsFilePath() string {
+	secretsFile := viper.GetString("SENHASEGURA_SECRETS_FILE")
+	if secretsFile == "" {
+		secretsFile = ".runb.vars"
+	}
+
+	return secretsFile
+}
+
+/**
+ * Replaces the secrets file with content, refusing directories other
+ * users could tamper with
+ */
+func writeSecretsFile(content []byte) error {
+	path := secretsFilePath()
+
+	err := checkSecretsDir(filepath.Dir(path), "SENHASEGURA_SECRETS_FILE")
+	if err != nil {
+		return err
+	}
+
+	err = writeFileAtomic(path, content)
+	if err != nil {
+		return err
+	}
+
+	recordInjectedFile(path)
+	return nil
+}
+
+/**
+ * Fails if dir is world-writable, as anyone could then swap the secrets
+ * files or read them before they are renamed into place. config names the
+ * setting that moves the secrets elsewhere.
+ */
+func checkSecretsDir(dir string, config string) error {
+	// Windows reports every directory as world-writable
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+
+	if info.Mode().Perm()&0002 != 0 {
+		return errors.Errorf("Refusing to write secrets to the world-writable directory '%s', set %s to a private location", dir, config)
+	}
+
+	return nil
+}
+
+/**
+ * Writes content to a temporary file readable only by the current user,
+ * syncs it and renames it over path, so readers never see a partial or
+ * stale file. Symbolic links at path are not followed.
+ */
+func writeFileAtomic(path string, content []byte) error {
+	info, err := os.Lstat(path)
+	if err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return errors.Errorf("Refusing to write secrets to '%s', it is a symbolic link", path)
+	}
+	if err == nil && !info.Mode().IsRegular() {
+		return errors.Errorf("Refusing to write secrets to '%s', it is not a regular file", path)
+	}
+
+	dir := filepath.Dir(path)
+
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = tmp.Chmod(0600)
+	if err == nil {
+		_, err = tmp.Write(content)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrapf(err, "Error writing '%s'", path)
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return err
+	}
+
+	syncDir(dir)
+
+	return nil
+}
+
+/**
+ * Persists the rename on file systems that need the directory synced.
+ * Not every platform can open directories, so failures are ignored.
+ */
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+
+	d.Sync()
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package dsm
+
+import (
+	"os"
+	"syscall"
+)
+
+// reloadSignals are the signals the agent can send to a supervised process.
+var reloadSignals = map[string]os.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"TERM": syscall.SIGTERM,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
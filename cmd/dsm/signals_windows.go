@@ -0,0 +1,14 @@
+//go:build windows
+// +build windows
+
+package dsm
+
+import (
+	"os"
+)
+
+// reloadSignals are the signals the agent can send to a supervised process.
+// Windows can only kill a process.
+var reloadSignals = map[string]os.Signal{
+	"KILL": os.Kill,
+}
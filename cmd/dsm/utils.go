@@ -1,22 +1,115 @@
 package dsm
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"strings"
+	"time"
 
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+
+	isoSdk "github.com/senhasegura/dsmcli/sdk/iso"
 )
 
-func getConfig() (string, string, string, bool) {
+var Timeout time.Duration
+
+/**
+ * Returns the command context limited by the --timeout flag
+ */
+func commandContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if Timeout > 0 {
+		return context.WithTimeout(ctx, Timeout)
+	}
+
+	return context.WithCancel(ctx)
+}
+
+func getConfig() (string, string, string, bool, error) {
 	if !IsSet("SENHASEGURA_URL", "SENHASEGURA_CLIENT_ID", "SENHASEGURA_CLIENT_SECRET") {
-		log.Fatalf("Authentication data not found or missing parameters\n")
+		return "", "", "", false, errors.Errorf("Authentication data not found or missing parameters")
 	}
 
 	return viper.GetString("SENHASEGURA_URL"),
 		viper.GetString("SENHASEGURA_CLIENT_ID"),
 		viper.GetString("SENHASEGURA_CLIENT_SECRET"),
-		Verbose
+		Verbose,
+		nil
+}
+
+func getTLSOptions() isoSdk.TLSOptions {
+	return isoSdk.TLSOptions{
+		InsecureSkipVerify: viper.GetBool("SENHASEGURA_INSECURE_SKIP_VERIFY"),
+		CAFile:             viper.GetString("SENHASEGURA_CA_BUNDLE"),
+		CertFile:           viper.GetString("SENHASEGURA_CLIENT_CERT"),
+		KeyFile:            viper.GetString("SENHASEGURA_CLIENT_KEY"),
+		PinnedSHA256:       getList("SENHASEGURA_PINNED_SHA256"),
+	}
+}
+
+// getGitlabTLSOptions describes how the GitLab API is verified, which is
+// usually signed by a different CA than senhasegura.
+func getGitlabTLSOptions() isoSdk.TLSOptions {
+	return isoSdk.TLSOptions{
+		InsecureSkipVerify: viper.GetBool("GITLAB_INSECURE_SKIP_VERIFY"),
+		CAFile:             viper.GetString("GITLAB_CA_BUNDLE"),
+		CertFile:           viper.GetString("GITLAB_CLIENT_CERT"),
+		KeyFile:            viper.GetString("GITLAB_CLIENT_KEY"),
+	}
+}
+
+func getRetryPolicy() isoSdk.RetryPolicy {
+	policy := isoSdk.DefaultRetryPolicy
+
+	if viper.IsSet("SENHASEGURA_RETRY_MAX_ATTEMPTS") {
+		policy.MaxAttempts = viper.GetInt("SENHASEGURA_RETRY_MAX_ATTEMPTS")
+	}
+	if viper.IsSet("SENHASEGURA_RETRY_BASE_DELAY") {
+		policy.BaseDelay = viper.GetDuration("SENHASEGURA_RETRY_BASE_DELAY")
+	}
+	if viper.IsSet("SENHASEGURA_RETRY_MAX_DELAY") {
+		policy.MaxDelay = viper.GetDuration("SENHASEGURA_RETRY_MAX_DELAY")
+	}
+
+	return policy
+}
+
+func newClient() (isoSdk.Client, error) {
+	url, clientID, clientSecret, verbose, err := getConfig()
+	if err != nil {
+		return isoSdk.Client{}, err
+	}
+
+	opts := []isoSdk.Option{
+		isoSdk.WithTLS(getTLSOptions()),
+		isoSdk.WithRetryPolicy(getRetryPolicy()),
+	}
+	// A dry run must not leave anything behind, not even a cached token
+	if dir := viper.GetString("SENHASEGURA_TOKEN_CACHE_DIR"); dir != "" && !DryRun {
+		opts = append(opts, isoSdk.WithTokenCache(dir))
+	}
+
+	return isoSdk.NewClient(url, clientID, clientSecret, verbose, opts...)
+}
+
+// getList reads a config key holding either a YAML list or a comma or
+// space separated string.
+func getList(name string) []string {
+	var list []string
+
+	for _, value := range viper.GetStringSlice(name) {
+		list = append(list, strings.FieldsFunc(value, func(r rune) bool {
+			return r == ',' || r == ' '
+		})...)
+	}
+
+	return list
 }
 
 func IsSet(name ...string) bool {
@@ -0,0 +1,137 @@
+package dsm
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// writeFunc renders a single variable in the syntax a tool reads it from.
+type writeFunc func(key, value string) string
+
+/**
+ * Script appending the variable to GITHUB_ENV. The delimiter syntax keeps
+ * multi-line values intact.
+ */
+func writeGithub(key, value string) string {
+	delimiter := heredocDelimiter(value)
+	return shellPrintf(key+"<<"+delimiter, value, delimiter) + " >> \"$GITHUB_ENV\"\n"
+}
+
+/**
+ * Script printing the setvariable logging command of Azure Pipelines
+ */
+func writeAzureDevops(key, value string) string {
+	return shellPrintf(fmt.Sprintf(
+		"##vso[task.setvariable variable=%s;issecret=true;]%s",
+		azureEscapeProperty(key),
+		azureEscapeData(value),
+	)) + "\n"
+}
+
+/**
+ * Java properties file, read by the Bamboo Inject Variables task, the
+ * Jenkins EnvInject plugin and the readProperties pipeline step
+ */
+func writeProperties(key, value string) string {
+	return propertiesEscape(key, true) + "=" + propertiesEscape(value, false) + "\n"
+}
+
+/**
+ * Script exporting the variable, sourced by later commands of the step or
+ * by the Buildkite agent as an environment hook
+ */
+func writeExport(key, value string) string {
+	return fmt.Sprintf("export %s=%s\n", key, shellQuote(value))
+}
+
+/**
+ * Script appending the export to BASH_ENV, which CircleCI sources before
+ * every following step
+ */
+func writeCircleci(key, value string) string {
+	return shellPrintf(fmt.Sprintf("export %s=%s", key, shellQuote(value))) + " >> \"$BASH_ENV\"\n"
+}
+
+/**
+ * Script printing the setParameter service message of TeamCity. The env.
+ * prefix makes the parameter an environment variable of later steps.
+ */
+func writeTeamcity(key, value string) string {
+	return shellPrintf(fmt.Sprintf(
+		"##teamcity[setParameter name='env.%s' value='%s']",
+		teamcityEscape(key),
+		teamcityEscape(value),
+	)) + "\n"
+}
+
+/**
+ * Script declaring the variable as exported, to be sourced by the shell
+ */
+func writeLinux(key, value string) string {
+	return fmt.Sprintf("declare -x %s=%s\n", key, shellQuote(value))
+}
+
+/**
+ * Escapes a key or value of a Java properties file, as written by
+ * java.util.Properties.store. Non-ASCII characters become \uXXXX escapes
+ * because properties files are read as ISO 8859-1.
+ */
+func propertiesEscape(value string, isKey bool) string {
+	var b strings.Builder
+
+	for i, r := range value {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '=', ':', '#', '!':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case ' ':
+			if i == 0 || isKey {
+				b.WriteRune('\\')
+			}
+			b.WriteRune(r)
+		default:
+			if r < 0x20 || r > 0x7e {
+				for _, unit := range utf16.Encode([]rune{r}) {
+					fmt.Fprintf(&b, `\u%04X`, unit)
+				}
+				continue
+			}
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+/**
+ * Workflow command registering the value as a secret in the GitHub logs
+ */
+func maskGithub(value string) string {
+	return "::add-mask::" + githubEscapeData(value) + "\n"
+}
+
+/**
+ * Logging command registering the value as a secret in the Azure logs
+ */
+func maskAzureDevops(value string) string {
+	return "##vso[task.setsecret]" + azureEscapeData(value) + "\n"
+}
+
+/**
+ * Command adding the value to the redactor of the Buildkite agent, which
+ * replaces it in the job logs. Agents without the redactor are ignored.
+ */
+func maskBuildkite(value string) string {
+	return shellPrintf(value) + " | buildkite-agent redactor add --format none 2>/dev/null || true\n"
+}
@@ -0,0 +1,233 @@
+package dsm
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// hostileValues try to break out of the quoting of every writer.
+var hostileValues = map[string]string{
+	"empty":          "",
+	"single quote":   "it's",
+	"quotes only":    `'"'"`,
+	"substitution":   "$(touch pwned)",
+	"backticks":      "`touch pwned`",
+	"variable":       "${HOME} $PATH",
+	"newline":        "line1\nline2\n",
+	"carriage":       "line1\r\nline2\r",
+	"percent":        "100% %0A %25 %AZP25",
+	"bracket":        "a]b[c]",
+	"pipe":           "a|b||c",
+	"unicode lines":  "a\u0085b\u2028c\u2029d",
+	"backslash":      `C:\new\table \\ \n`,
+	"printf option":  "-n",
+	"printf format":  "%s%d%n",
+	"github command": "::add-mask::x\n::set-env name=X::y",
+	"azure command":  "##vso[task.setvariable variable=X]evil",
+	"teamcity":       "##teamcity[buildStatus text='pwned']",
+}
+
+/**
+ * Runs script with the given shell in a scratch directory, failing the
+ * test if it created the file the hostile values try to touch
+ */
+func runShell(t *testing.T, shell string, script string, env ...string) string {
+	t.Helper()
+
+	path, err := exec.LookPath(shell)
+	if err != nil {
+		t.Skipf("%s is not available", shell)
+	}
+
+	dir := t.TempDir()
+
+	cmd := exec.Command(path, "-c", script)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("%s failed: %s\n%s", shell, err, stderr.String())
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "pwned")); err == nil {
+		t.Fatalf("script executed a command embedded in the value")
+	}
+
+	return string(out)
+}
+
+func TestWriteLinux(t *testing.T) {
+	for name, value := range hostileValues {
+		t.Run(name, func(t *testing.T) {
+			got := runShell(t, "bash", writeLinux("SECRET", value)+`printf '%s' "$SECRET"`)
+			if got != value {
+				t.Errorf("round trip = %q, want %q", got, value)
+			}
+		})
+	}
+}
+
+func TestWriteExport(t *testing.T) {
+	for name, value := range hostileValues {
+		t.Run(name, func(t *testing.T) {
+			got := runShell(t, "sh", writeExport("SECRET", value)+`sh -c 'printf "%s" "$SECRET"'`)
+			if got != value {
+				t.Errorf("round trip = %q, want %q", got, value)
+			}
+		})
+	}
+}
+
+func TestWriteGithub(t *testing.T) {
+	for name, value := range hostileValues {
+		t.Run(name, func(t *testing.T) {
+			githubEnv := filepath.Join(t.TempDir(), "github_env")
+
+			runShell(t, "sh", writeGithub("SECRET", value), "GITHUB_ENV="+githubEnv)
+
+			content, err := ioutil.ReadFile(githubEnv)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// SECRET<<delimiter, the value and the delimiter on its own line
+			lines := strings.SplitN(string(content), "\n", 2)
+			if len(lines) != 2 || !strings.HasPrefix(lines[0], "SECRET<<") {
+				t.Fatalf("unexpected GITHUB_ENV content %q", content)
+			}
+
+			delimiter := strings.TrimPrefix(lines[0], "SECRET<<")
+			if strings.Contains(value, delimiter) {
+				t.Fatalf("delimiter %q occurs in the value", delimiter)
+			}
+
+			got := strings.TrimSuffix(lines[1], "\n"+delimiter+"\n")
+			if got == lines[1] {
+				t.Fatalf("GITHUB_ENV content %q does not end with the delimiter", content)
+			}
+
+			if got != value {
+				t.Errorf("round trip = %q, want %q", got, value)
+			}
+		})
+	}
+}
+
+func TestWriteAzureDevops(t *testing.T) {
+	unescape := strings.NewReplacer("%0D", "\r", "%0A", "\n", "%3B", ";", "%5D", "]", "%AZP25", "%")
+
+	for name, value := range hostileValues {
+		t.Run(name, func(t *testing.T) {
+			out := runShell(t, "sh", writeAzureDevops("SECRET", value))
+
+			prefix := "##vso[task.setvariable variable=SECRET;issecret=true;]"
+			if !strings.HasPrefix(out, prefix) || strings.Count(out, "\n") != 1 || !strings.HasSuffix(out, "\n") {
+				t.Fatalf("output %q is not a single logging command", out)
+			}
+
+			got := unescape.Replace(strings.TrimSuffix(strings.TrimPrefix(out, prefix), "\n"))
+			if got != value {
+				t.Errorf("round trip = %q, want %q", got, value)
+			}
+		})
+	}
+}
+
+func TestWriteTeamcity(t *testing.T) {
+	for name, value := range hostileValues {
+		t.Run(name, func(t *testing.T) {
+			out := runShell(t, "sh", writeTeamcity("SECRET", value))
+
+			prefix, suffix := "##teamcity[setParameter name='env.SECRET' value='", "']\n"
+			if !strings.HasPrefix(out, prefix) || !strings.HasSuffix(out, suffix) || strings.Count(out, "\n") != 1 {
+				t.Fatalf("output %q is not a single service message", out)
+			}
+
+			got, err := teamcityUnescape(strings.TrimSuffix(strings.TrimPrefix(out, prefix), suffix))
+			if err != "" {
+				t.Fatalf("output %q: %s", out, err)
+			}
+
+			if got != value {
+				t.Errorf("round trip = %q, want %q", got, value)
+			}
+		})
+	}
+}
+
+/**
+ * Reverses teamcityEscape, reporting characters that would end the
+ * service message early
+ */
+func teamcityUnescape(escaped string) (string, string) {
+	replacements := map[rune]string{'|': "|", '\'': "'", 'n': "\n", 'r': "\r", '[': "[", ']': "]", 'x': "\u0085", 'l': "\u2028", 'p': "\u2029"}
+
+	var b strings.Builder
+	runes := []rune(escaped)
+
+	for i := 0; i < len(runes); i++ {
+		switch runes[i] {
+		case '|':
+			if i+1 == len(runes) {
+				return "", "dangling escape"
+			}
+			replacement, ok := replacements[runes[i+1]]
+			if !ok {
+				return "", "unknown escape |" + string(runes[i+1])
+			}
+			b.WriteString(replacement)
+			i++
+		case '\'', ']', '\n', '\r', '\u0085', '\u2028', '\u2029':
+			return "", "unescaped " + string(runes[i])
+		default:
+			b.WriteRune(runes[i])
+		}
+	}
+
+	return b.String(), ""
+}
+
+func TestWritersGolden(t *testing.T) {
+	tests := []struct {
+		tool  string
+		write writeFunc
+	}{
+		{"bamboo", writeProperties},
+		{"bitbucket", writeExport},
+		{"circleci", writeCircleci},
+		{"teamcity", writeTeamcity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tool, func(t *testing.T) {
+			var got strings.Builder
+			for _, variable := range goldenVariables {
+				got.WriteString(tt.write(variable.Key, variable.Value))
+			}
+
+			checkGolden(t, filepath.Join("writers", tt.tool+".golden"), []byte(got.String()))
+		})
+	}
+}
+
+func TestWriteCircleci(t *testing.T) {
+	for name, value := range hostileValues {
+		t.Run(name, func(t *testing.T) {
+			bashEnv := filepath.Join(t.TempDir(), "bash_env")
+
+			got := runShell(t, "sh", writeCircleci("SECRET", value)+`. "$BASH_ENV" && printf '%s' "$SECRET"`, "BASH_ENV="+bashEnv)
+			if got != value {
+				t.Errorf("round trip = %q, want %q", got, value)
+			}
+		})
+	}
+}
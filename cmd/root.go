@@ -16,10 +16,13 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -40,15 +43,30 @@ Using this plugin, DevOps teams have an easy way to centralize application and s
 }
 
 func Execute() {
-	cobra.CheckErr(rootCmd.Execute())
+	// Cancel in-flight requests when the CI runner stops the step
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		stop()
+		os.Exit(dsm.ExitCode(err))
+	}
 }
 
 func init() {
 	cobra.OnInitialize(initConfig)
 
 	rootCmd.PersistentFlags().StringVarP(&Config, "config", "c", "", "Configuration file (default is $HOME/.config.yaml)")
+	rootCmd.PersistentFlags().DurationVar(&dsm.Timeout, "timeout", 0, "Maximum time to wait for senhasegura, e.g. 30s or 2m (default no limit)")
 
 	rootCmd.AddCommand(dsm.RunbCmd)
+	rootCmd.AddCommand(dsm.ExecCmd)
+	rootCmd.AddCommand(dsm.CleanupCmd)
+	rootCmd.AddCommand(dsm.MappingCmd)
+	rootCmd.AddCommand(dsm.SecretCmd)
+	rootCmd.AddCommand(dsm.AppCmd)
+	rootCmd.AddCommand(dsm.AgentCmd)
+	rootCmd.AddCommand(dsm.MountCmd)
 }
 
 // initConfig reads in config file and ENV variables if set.
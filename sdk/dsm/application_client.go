@@ -1,9 +1,10 @@
 package dsm
 
 import (
+	"context"
 	"fmt"
 	"net/url"
-	"os"
+	"strings"
 
 	sdk "github.com/senhasegura/dsmcli/sdk/iso"
 )
@@ -13,25 +14,29 @@ type ApplicationClient struct {
 	name        string
 	system      string
 	environment string
+	details     ApplicationDetails
+}
+
+// ApplicationDetails are the optional attributes describing an application.
+type ApplicationDetails struct {
+	Description string
+	Tags        []string
 }
 
 /**
  * Constructor for ApplicationClient
  */
-func NewApplicationClient(client *sdk.Client, name string, environment string, system string) ApplicationClient {
+func NewApplicationClient(client *sdk.Client, name string, environment string, system string) (ApplicationClient, error) {
 	if string(name) == "" {
-		fmt.Println("Error: Application name must be defined")
-		os.Exit(1)
+		return ApplicationClient{}, fmt.Errorf("Application name must be defined")
 	}
 
 	if string(environment) == "" {
-		fmt.Println("Error: Environment must be defined")
-		os.Exit(1)
+		return ApplicationClient{}, fmt.Errorf("Environment must be defined")
 	}
 
 	if string(system) == "" {
-		fmt.Println("Error: System must be defined")
-		os.Exit(1)
+		return ApplicationClient{}, fmt.Errorf("System must be defined")
 	}
 
 	a := ApplicationClient{
@@ -41,7 +46,14 @@ func NewApplicationClient(client *sdk.Client, name string, environment string, s
 		client:      client,
 	}
 
-	return a
+	return a, nil
+}
+
+/**
+ * Sets the description and tags sent when the application is registered
+ */
+func (a *ApplicationClient) SetDetails(details ApplicationDetails) {
+	a.details = details
 }
 
 /**
@@ -49,18 +61,22 @@ func NewApplicationClient(client *sdk.Client, name string, environment string, s
  * "POST /iso/dapp/Application"
  */
 func (a *ApplicationClient) Register() (ApplicationResponse, error) {
-	a.client.V("Registering Application on DevSecOps\n")
+	return a.RegisterContext(context.Background())
+}
 
-	a.client.Authenticate()
+/**
+ * Registers the application like Register, aborting when ctx is done
+ */
+func (a *ApplicationClient) RegisterContext(ctx context.Context) (ApplicationResponse, error) {
+	a.client.V("Registering Application on DevSecOps\n")
 
-	data := url.Values{
-		"application": {a.name},
-		"environment": {a.environment},
-		"system":      {a.system},
+	err := a.client.AuthenticateContext(ctx)
+	if err != nil {
+		return ApplicationResponse{}, err
 	}
 
 	var appResp ApplicationResponse
-	err := a.client.Post("/iso/dapp/Application", data, &appResp)
+	err = a.client.PostContext(ctx, "/iso/dapp/Application", a.values(), &appResp)
 	if err != nil {
 		return ApplicationResponse{}, err
 	}
@@ -81,10 +97,20 @@ func (a *ApplicationClient) DefineCredentialsByApplication(application Applicati
  * to get Application
  */
 func (a ApplicationClient) GetApplication() (ApplicationResponse, error) {
-	a.client.Authenticate()
+	return a.GetApplicationContext(context.Background())
+}
+
+/**
+ * Gets the application like GetApplication, aborting when ctx is done
+ */
+func (a ApplicationClient) GetApplicationContext(ctx context.Context) (ApplicationResponse, error) {
+	err := a.client.AuthenticateContext(ctx)
+	if err != nil {
+		return ApplicationResponse{}, err
+	}
 
 	var appResp ApplicationResponse
-	err := a.client.Get("/iso/dapp/Application", url.Values{}, &appResp)
+	err = a.client.GetContext(ctx, "/iso/dapp/Application", url.Values{}, &appResp)
 	if err != nil {
 		return ApplicationResponse{}, err
 	}
@@ -96,10 +122,17 @@ func (a ApplicationClient) GetApplication() (ApplicationResponse, error) {
  * Makes requests for /iso/dapp/Application
  * to get secrets of Application
  */
-func (a ApplicationClient) GetSecrets() (secrets, error) {
+func (a ApplicationClient) GetSecrets() (Secrets, error) {
+	return a.GetSecretsContext(context.Background())
+}
+
+/**
+ * Gets the secrets like GetSecrets, aborting when ctx is done
+ */
+func (a ApplicationClient) GetSecretsContext(ctx context.Context) (Secrets, error) {
 	a.client.V("Finding secrets from application\n")
 
-	app, err := a.GetApplication()
+	app, err := a.GetApplicationContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -109,4 +142,118 @@ func (a ApplicationClient) GetSecrets() (secrets, error) {
 
 func (a ApplicationClient) GetClient() *sdk.Client {
 	return a.client
-}
\ No newline at end of file
+}
+
+/**
+ * Gets the application by its name, system and environment, with the
+ * credentials the client was created with, without registering it
+ * "GET /iso/dapp/Application"
+ */
+func (a *ApplicationClient) Find() (ApplicationResponse, error) {
+	return a.FindContext(context.Background())
+}
+
+/**
+ * Finds the application like Find, aborting when ctx is done
+ */
+func (a *ApplicationClient) FindContext(ctx context.Context) (ApplicationResponse, error) {
+	err := a.client.AuthenticateContext(ctx)
+	if err != nil {
+		return ApplicationResponse{}, err
+	}
+
+	var appResp ApplicationResponse
+	err = a.client.GetContext(ctx, "/iso/dapp/Application", a.identity(), &appResp)
+	if err != nil {
+		return ApplicationResponse{}, err
+	}
+
+	return appResp, nil
+}
+
+/**
+ * Updates the description and tags of the application, empty ones are
+ * left unchanged
+ * "PUT /iso/dapp/Application"
+ */
+func (a *ApplicationClient) Update(details ApplicationDetails) (ApplicationResponse, error) {
+	return a.UpdateContext(context.Background(), details)
+}
+
+/**
+ * Updates the application like Update, aborting when ctx is done
+ */
+func (a *ApplicationClient) UpdateContext(ctx context.Context, details ApplicationDetails) (ApplicationResponse, error) {
+	a.client.V("Updating Application on DevSecOps\n")
+
+	err := a.client.AuthenticateContext(ctx)
+	if err != nil {
+		return ApplicationResponse{}, err
+	}
+
+	a.details = details
+
+	var appResp ApplicationResponse
+	err = a.client.PutContext(ctx, "/iso/dapp/Application", a.values(), &appResp)
+	if err != nil {
+		return ApplicationResponse{}, err
+	}
+
+	return appResp, nil
+}
+
+/**
+ * Deletes the application and its authorization
+ * "DELETE /iso/dapp/Application"
+ */
+func (a *ApplicationClient) Delete() (ApplicationResponse, error) {
+	return a.DeleteContext(context.Background())
+}
+
+/**
+ * Deletes the application like Delete, aborting when ctx is done
+ */
+func (a *ApplicationClient) DeleteContext(ctx context.Context) (ApplicationResponse, error) {
+	a.client.V("Deleting Application on DevSecOps\n")
+
+	err := a.client.AuthenticateContext(ctx)
+	if err != nil {
+		return ApplicationResponse{}, err
+	}
+
+	var appResp ApplicationResponse
+	err = a.client.DeleteContext(ctx, "/iso/dapp/Application", a.identity(), &appResp)
+	if err != nil {
+		return ApplicationResponse{}, err
+	}
+
+	return appResp, nil
+}
+
+/**
+ * Returns the attributes identifying the application
+ */
+func (a *ApplicationClient) identity() url.Values {
+	return url.Values{
+		"application": {a.name},
+		"environment": {a.environment},
+		"system":      {a.system},
+	}
+}
+
+/**
+ * Returns the attributes identifying the application, with its details
+ */
+func (a *ApplicationClient) values() url.Values {
+	data := a.identity()
+
+	if a.details.Description != "" {
+		data.Set("description", a.details.Description)
+	}
+
+	if len(a.details.Tags) > 0 {
+		data.Set("tags", strings.Join(a.details.Tags, ","))
+	}
+
+	return data
+}
@@ -3,11 +3,11 @@ package dsm
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
-	"path"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -32,10 +32,11 @@ type Application struct {
 	Tags        []string `json:"tags"`
 	System      string   `json:"system"`
 	Environment string   `json:"Environment"`
-	Secrets     secrets  `json:"secrets"`
+	Secrets     Secrets  `json:"secrets"`
 }
 
-type secrets []Secret
+// Secrets are the secrets an application has access to.
+type Secrets []Secret
 
 type Secret struct {
 	SecretID       string              `json:"secret_id"`
@@ -98,34 +99,14 @@ func (r *ApplicationResponse) GetEntity() interface{} {
 func (a *ApplicationResponse) SaveToFile() error {
 	fmt.Println("Adding credentials to system...")
 
-	secretDirectory := viper.GetString("SENHASEGURA_SECRETS_FOLDER") + "/senhasegura/iso"
-	err := os.MkdirAll(secretDirectory, os.ModePerm)
-	if err != nil {
-		return err
-	}
-
-	err = ioutil.WriteFile(
-		secretDirectory+"/SENHASEGURA_URL",
-		[]byte(viper.GetString("SENHASEGURA_URL")),
-		os.ModePerm,
-	)
-	if err != nil {
-		return err
-	}
-
-	err = ioutil.WriteFile(
-		secretDirectory+"/SENHASEGURA_CLIENT_ID",
-		[]byte(a.ID),
-		os.ModePerm,
-	)
-	if err != nil {
-		return err
-	}
-
-	err = ioutil.WriteFile(
-		secretDirectory+"/SENHASEGURA_CLIENT_SECRET",
-		[]byte(a.Signature),
-		os.ModePerm,
+	err := writeVolume(
+		filepath.Join(SecretsFolder(), "senhasegura", "iso"),
+		map[string][]byte{
+			"SENHASEGURA_URL":           []byte(viper.GetString("SENHASEGURA_URL")),
+			"SENHASEGURA_CLIENT_ID":     []byte(a.ID),
+			"SENHASEGURA_CLIENT_SECRET": []byte(a.Signature),
+		},
+		VolumeMetadata{UpdatedAt: time.Now().UTC()},
 	)
 	if err != nil {
 		return err
@@ -137,92 +118,87 @@ func (a *ApplicationResponse) SaveToFile() error {
 
 /**
  * Save multiple credentials on folders
- * "/var/run/secrets/senhasegura/[application_name]"
+ * "/var/run/secrets/senhasegura/[secret_identifier]"
  */
-func (s secrets) SaveToFile() error {
+func (s Secrets) SaveToFile() error {
 	fmt.Println("Adding credentials to system...")
 
-	secretDirectory := viper.GetString("SENHASEGURA_SECRETS_FOLDER") + "/senhasegura"
-
-	err := os.MkdirAll(secretDirectory, os.ModePerm)
-	if err != nil {
-		return err
-	}
-
-	err = RemoveContents(secretDirectory)
-	if err != nil {
-		return err
-	}
-
-	// Read dirs and files inside /var/run/secrets/senhasegura
-	dir, err := ioutil.ReadDir(secretDirectory)
-	if err != nil {
-		return err
-	}
-
-	// Remove all dirs and files inside /var/run/secrets/senhasegura
-	for _, d := range dir {
-		err := os.RemoveAll(path.Join([]string{secretDirectory, d.Name()}...))
+	files := make(map[string][]byte)
+	versions := make(map[string]string, len(s))
 
+	for _, secret := range s {
+		err := secret.addFiles(files)
 		if err != nil {
 			return err
 		}
+
+		versions[secret.Identity] = secret.Version
 	}
 
-	err = os.MkdirAll(secretDirectory, os.ModePerm)
+	err := writeVolume(
+		filepath.Join(SecretsFolder(), "senhasegura"),
+		files,
+		VolumeMetadata{UpdatedAt: time.Now().UTC(), Versions: versions},
+	)
 	if err != nil {
 		return err
 	}
 
-	for _, secret := range s {
-		folder := fmt.Sprintf(
-			"%s/%s",
-			secretDirectory,
-			secret.Identity,
-		)
-
-		err = os.MkdirAll(folder, os.ModePerm)
-		if err != nil {
-			return err
-		}
-
-		secret.saveToFile(folder)
-	}
-
 	fmt.Println("Complete.")
 	return nil
 }
 
-func (s secrets) GetMinTTL() int64 {
-	var ttl int64 = 120
+// DefaultTTL is the refresh interval in seconds of secrets without a TTL.
+const DefaultTTL = 120
+
+// MinTTL is the shortest refresh interval in seconds, so secrets with a
+// very short TTL do not flood senhasegura with requests.
+const MinTTL = 10
+
+/**
+ * Returns the shortest TTL in seconds defined by the secrets, no shorter
+ * than MinTTL, and DefaultTTL when none of them defines one
+ */
+func (s Secrets) GetMinTTL() int64 {
+	var ttl int64
 
 	for _, secret := range s {
 		ttl = secret.getMinTTL(ttl)
 	}
 
+	if ttl == 0 {
+		return DefaultTTL
+	}
+
 	return ttl
 }
 
 /**
- * Save the data of secret on folders
- * "/etc/run/secrets/senhasegura/[application_name]/[secret_identifier]"
+ * Adds the data of secret to files as
+ * "[secret_identifier]/[key]"
  */
-func (s Secret) saveToFile(folder string) error {
+func (s Secret) addFiles(files map[string][]byte) error {
+	if s.Identity == "" || strings.ContainsAny(s.Identity, `/\`) {
+		return fmt.Errorf("Invalid secret identity '%s'", s.Identity)
+	}
+
 	for _, data := range s.Data {
-		for filename, content := range data {
-			err := ioutil.WriteFile(
-				fmt.Sprintf("%s/%s", folder, filename),
-				[]byte(content),
-				os.ModePerm,
-			)
-			if err != nil {
-				return err
+		for key, content := range data {
+			if strings.ContainsAny(key, `/\`) {
+				return fmt.Errorf("Invalid key '%s' in secret %s", key, s.Identity)
 			}
+
+			files[filepath.Join(s.Identity, key)] = []byte(content)
 		}
 	}
+
 	return nil
 }
 
+/**
+ * Returns the shortest of current and the TTL of the secret, current being
+ * 0 when no TTL was found yet
+ */
 func (s Secret) getMinTTL(current int64) int64 {
 	newTTL := current
 
@@ -230,7 +206,15 @@ func (s Secret) getMinTTL(current int64) int64 {
 		for key, value := range data {
 			if key == "TTL" && value != "" {
 				ttl, err := strconv.ParseInt(value, 10, 64)
-				if err != nil && ttl > 10 && ttl < newTTL {
+				if err != nil || ttl <= 0 {
+					continue
+				}
+
+				if ttl < MinTTL {
+					ttl = MinTTL
+				}
+
+				if newTTL == 0 || ttl < newTTL {
 					newTTL = ttl
 				}
 			}
@@ -240,6 +224,17 @@ func (s Secret) getMinTTL(current int64) int64 {
 	return newTTL
 }
 
+/**
+ * Returns SENHASEGURA_SECRETS_FOLDER, /var/run/secrets by default
+ */
+func SecretsFolder() string {
+	if folder := viper.GetString("SENHASEGURA_SECRETS_FOLDER"); folder != "" {
+		return folder
+	}
+
+	return "/var/run/secrets"
+}
+
 // Remove o conteudo de um diretorio
 func RemoveContents(dir string) error {
 	d, err := os.Open(dir)
@@ -0,0 +1,34 @@
+package dsm
+
+import "testing"
+
+func ttlSecrets(ttls ...string) Secrets {
+	secrets := make(Secrets, len(ttls))
+	for i, ttl := range ttls {
+		secrets[i] = Secret{Data: []map[string]string{{"TTL": ttl}}}
+	}
+
+	return secrets
+}
+
+func TestGetMinTTL(t *testing.T) {
+	tests := []struct {
+		name string
+		ttls []string
+		want int64
+	}{
+		{"no secrets", nil, DefaultTTL},
+		{"no TTL", []string{"", "invalid", "0", "-5"}, DefaultTTL},
+		{"shortest", []string{"300", "60", "900"}, 60},
+		{"short TTLs are clamped", []string{"300", "5"}, MinTTL},
+		{"exactly the floor", []string{"10", "300"}, MinTTL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ttlSecrets(tt.ttls...).GetMinTTL(); got != tt.want {
+				t.Errorf("GetMinTTL() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package dsm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// AccessKeyTypes are the clouds whose access keys senhasegura DSM can
+// register through the Cloud IAM module.
+var AccessKeyTypes = []string{"aws", "azure", "gcp"}
+
+// Mapping tells senhasegura DSM which pipeline variables hold secrets to
+// be registered, as read from the SENHASEGURA_MAPPING_FILE.
+type Mapping struct {
+	AccessKeys  []AccessKeyMapping  `json:"access_keys,omitempty" yaml:"access_keys,omitempty"`
+	Credentials []CredentialMapping `json:"credentials,omitempty" yaml:"credentials,omitempty"`
+	KeyValue    []KeyValueMapping   `json:"key_value,omitempty" yaml:"key_value,omitempty"`
+}
+
+// AccessKeyMapping names the variables holding a cloud access key.
+type AccessKeyMapping struct {
+	Name   string          `json:"name" yaml:"name"`
+	Type   string          `json:"type" yaml:"type"`
+	Fields AccessKeyFields `json:"fields" yaml:"fields"`
+}
+
+type AccessKeyFields struct {
+	AccessKeyID     string `json:"access_key_id" yaml:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key" yaml:"secret_access_key"`
+}
+
+// CredentialMapping names the variables holding a PAM credential.
+type CredentialMapping struct {
+	Name   string           `json:"name" yaml:"name"`
+	Fields CredentialFields `json:"fields" yaml:"fields"`
+}
+
+type CredentialFields struct {
+	User     string `json:"user" yaml:"user"`
+	Password string `json:"password" yaml:"password"`
+	Host     string `json:"host,omitempty" yaml:"host,omitempty"`
+}
+
+// KeyValueMapping names the variables registered as generic secrets.
+type KeyValueMapping struct {
+	Name   string   `json:"name" yaml:"name"`
+	Fields []string `json:"fields" yaml:"fields"`
+}
+
+// MappingError lists every problem found in a mapping.
+type MappingError struct {
+	Problems []string
+}
+
+func (e *MappingError) Error() string {
+	return "Invalid mapping: " + strings.Join(e.Problems, "; ")
+}
+
+/**
+ * Parses a mapping written in JSON or YAML, rejecting unknown attributes
+ */
+func ParseMapping(content []byte) (Mapping, error) {
+	var mapping Mapping
+
+	trimmed := bytes.TrimSpace(content)
+
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		decoder := json.NewDecoder(bytes.NewReader(trimmed))
+		decoder.DisallowUnknownFields()
+
+		err := decoder.Decode(&mapping)
+		if err != nil {
+			return mapping, fmt.Errorf("Invalid JSON mapping: %w", err)
+		}
+
+		if decoder.More() {
+			return mapping, fmt.Errorf("Invalid JSON mapping: unexpected content after the mapping object")
+		}
+
+		return mapping, nil
+	}
+
+	err := yaml.UnmarshalStrict(trimmed, &mapping)
+	if err != nil {
+		return mapping, fmt.Errorf("Invalid YAML mapping: %w", err)
+	}
+
+	return mapping, nil
+}
+
+/**
+ * Checks the mapping against the schema senhasegura DSM accepts, returning
+ * a MappingError with every problem found
+ */
+func (m Mapping) Validate() error {
+	var problems []string
+	names := make(map[string]string)
+
+	checkName := func(block string, index int, name string) {
+		if name == "" {
+			problems = append(problems, fmt.Sprintf("%s[%d] has no name", block, index))
+			return
+		}
+
+		if previous, ok := names[name]; ok {
+			problems = append(problems, fmt.Sprintf("%s[%d] name '%s' is already used in %s", block, index, name, previous))
+			return
+		}
+
+		names[name] = fmt.Sprintf("%s[%d]", block, index)
+	}
+
+	for i, entry := range m.AccessKeys {
+		checkName("access_keys", i, entry.Name)
+
+		if !isAccessKeyType(entry.Type) {
+			problems = append(problems, fmt.Sprintf("access_keys[%d] type '%s' must be one of %s", i, entry.Type, strings.Join(AccessKeyTypes, ", ")))
+		}
+
+		if entry.Fields.AccessKeyID == "" || entry.Fields.SecretAccessKey == "" {
+			problems = append(problems, fmt.Sprintf("access_keys[%d] must define the access_key_id and secret_access_key fields", i))
+		}
+	}
+
+	for i, entry := range m.Credentials {
+		checkName("credentials", i, entry.Name)
+
+		if entry.Fields.User == "" || entry.Fields.Password == "" {
+			problems = append(problems, fmt.Sprintf("credentials[%d] must define the user and password fields", i))
+		}
+	}
+
+	for i, entry := range m.KeyValue {
+		checkName("key_value", i, entry.Name)
+
+		if len(entry.Fields) == 0 {
+			problems = append(problems, fmt.Sprintf("key_value[%d] must define at least one field", i))
+		}
+
+		for j, field := range entry.Fields {
+			if field == "" {
+				problems = append(problems, fmt.Sprintf("key_value[%d] field %d is empty", i, j))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &MappingError{Problems: problems}
+	}
+
+	return nil
+}
+
+/**
+ * Returns the names of the variables the mapping refers to
+ */
+func (m Mapping) Variables() []string {
+	var variables []string
+
+	add := func(names ...string) {
+		for _, name := range names {
+			if name != "" {
+				variables = append(variables, name)
+			}
+		}
+	}
+
+	for _, entry := range m.AccessKeys {
+		add(entry.Fields.AccessKeyID, entry.Fields.SecretAccessKey)
+	}
+
+	for _, entry := range m.Credentials {
+		add(entry.Fields.User, entry.Fields.Password, entry.Fields.Host)
+	}
+
+	for _, entry := range m.KeyValue {
+		add(entry.Fields...)
+	}
+
+	return variables
+}
+
+func isAccessKeyType(value string) bool {
+	for _, t := range AccessKeyTypes {
+		if strings.EqualFold(value, t) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,28 @@
+package dsm
+
+import "testing"
+
+func TestValidateIgnoresTypeCase(t *testing.T) {
+	for _, typ := range []string{"aws", "AWS", "Azure", "GCP"} {
+		mapping := Mapping{AccessKeys: []AccessKeyMapping{{
+			Name:   "CLOUD",
+			Type:   typ,
+			Fields: AccessKeyFields{AccessKeyID: "KEY_ID", SecretAccessKey: "SECRET_KEY"},
+		}}}
+
+		err := mapping.Validate()
+		if err != nil {
+			t.Errorf("type %s: %v", typ, err)
+		}
+	}
+
+	mapping := Mapping{AccessKeys: []AccessKeyMapping{{
+		Name:   "CLOUD",
+		Type:   "oracle",
+		Fields: AccessKeyFields{AccessKeyID: "KEY_ID", SecretAccessKey: "SECRET_KEY"},
+	}}}
+
+	if mapping.Validate() == nil {
+		t.Error("unsupported type accepted")
+	}
+}
@@ -0,0 +1,184 @@
+package dsm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+
+	sdk "github.com/senhasegura/dsmcli/sdk/iso"
+)
+
+const secretResource = "/iso/sctm/secret"
+
+type SecretClient struct {
+	client *sdk.Client
+}
+
+/**
+ * Constructor for SecretClient
+ */
+func NewSecretClient(client *sdk.Client) SecretClient {
+	a := SecretClient{
+		client: client,
+	}
+
+	return a
+}
+
+/**
+ * Gets a secret by its identity using the sctm endpoint
+ * "GET /iso/sctm/secret/[identity]"
+ */
+func (a *SecretClient) Get(identity string) (Secret, error) {
+	return a.GetContext(context.Background(), identity)
+}
+
+/**
+ * Gets a secret like Get, aborting when ctx is done
+ */
+func (a *SecretClient) GetContext(ctx context.Context, identity string) (Secret, error) {
+	err := a.client.AuthenticateContext(ctx)
+	if err != nil {
+		return Secret{}, err
+	}
+
+	var secretResp SecretResponse
+	err = a.client.GetContext(ctx, secretResource+"/"+url.PathEscape(identity), url.Values{}, &secretResp)
+	if err != nil {
+		return Secret{}, err
+	}
+
+	return secretResp.Secret, nil
+}
+
+/**
+ * Creates a secret, or replaces the data of an existing one, returning the
+ * secret with its new version, using the sctm endpoint
+ * "POST /iso/sctm/secret"
+ *
+ * When expectedVersion is not empty it is sent as "expected_version", and
+ * an error matching sdk.ErrConflict is returned when the current version
+ * does not match. Use "0" to only create a secret that does not exist yet.
+ *
+ * The version is also compared beforehand, to fail early, but that check
+ * is a separate request: it cannot stop two concurrent writers that both
+ * saw the expected version. Only a server enforcing "expected_version"
+ * rejects the second one, with 409 or 412.
+ */
+func (a *SecretClient) Put(identity string, data map[string]string, expectedVersion string) (Secret, error) {
+	return a.PutContext(context.Background(), identity, data, expectedVersion)
+}
+
+/**
+ * Puts a secret like Put, aborting when ctx is done
+ */
+func (a *SecretClient) PutContext(ctx context.Context, identity string, data map[string]string, expectedVersion string) (Secret, error) {
+	a.client.V("Saving secret %s...\n", identity)
+
+	if identity == "" {
+		return Secret{}, fmt.Errorf("Secret identity must be defined")
+	}
+
+	if len(data) == 0 {
+		return Secret{}, fmt.Errorf("Secret data must be defined")
+	}
+
+	err := a.checkVersion(ctx, identity, expectedVersion)
+	if err != nil {
+		return Secret{}, err
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return Secret{}, err
+	}
+
+	values := url.Values{
+		"identity": {identity},
+		"data":     {string(encoded)},
+	}
+	if expectedVersion != "" {
+		values.Set("expected_version", expectedVersion)
+	}
+
+	var secretResp SecretResponse
+	err = a.client.PostContext(ctx, secretResource, values, &secretResp)
+	if err != nil {
+		return Secret{}, err
+	}
+
+	a.client.V("Secret saved with version %s\n", secretResp.Secret.Version)
+
+	return secretResp.Secret, nil
+}
+
+/**
+ * Deletes a secret by its identity using the sctm endpoint
+ * "DELETE /iso/sctm/secret/[identity]?expected_version=[version]",
+ * checking expectedVersion like Put, with the same limits under concurrency
+ */
+func (a *SecretClient) Delete(identity string, expectedVersion string) error {
+	return a.DeleteContext(context.Background(), identity, expectedVersion)
+}
+
+/**
+ * Deletes a secret like Delete, aborting when ctx is done
+ */
+func (a *SecretClient) DeleteContext(ctx context.Context, identity string, expectedVersion string) error {
+	a.client.V("Deleting secret %s...\n", identity)
+
+	if identity == "" {
+		return fmt.Errorf("Secret identity must be defined")
+	}
+
+	err := a.checkVersion(ctx, identity, expectedVersion)
+	if err != nil {
+		return err
+	}
+
+	values := url.Values{}
+	if expectedVersion != "" {
+		values.Set("expected_version", expectedVersion)
+	}
+
+	var secretResp SecretResponse
+	err = a.client.DeleteContext(ctx, secretResource+"/"+url.PathEscape(identity), values, &secretResp)
+	if err != nil {
+		return err
+	}
+
+	a.client.V("Secret deleted\n")
+
+	return nil
+}
+
+/**
+ * Fails with sdk.ErrConflict when the current version of the secret is not
+ * the expected one. Version "0" expects the secret not to exist.
+ *
+ * This is best-effort only: the secret may change between this request and
+ * the write that follows it.
+ */
+func (a *SecretClient) checkVersion(ctx context.Context, identity string, expectedVersion string) error {
+	if expectedVersion == "" {
+		return nil
+	}
+
+	current := "0"
+
+	secret, err := a.GetContext(ctx, identity)
+	switch {
+	case err == nil:
+		current = secret.Version
+	case !errors.Is(err, sdk.ErrNotFound):
+		return err
+	}
+
+	if current != expectedVersion {
+		return fmt.Errorf("%w: secret %s is at version %s, expected %s", sdk.ErrConflict, identity, current, expectedVersion)
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package dsm
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sdk "github.com/senhasegura/dsmcli/sdk/iso"
+)
+
+/**
+ * Serves a secret at the given version, recording the query and body of
+ * the delete requests
+ */
+func secretServer(t *testing.T, version string) (*sdk.Client, *[]string) {
+	t.Helper()
+
+	var deletes []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/iso/oauth2/token":
+			w.Write([]byte(`{"access_token":"token","expires_in":3600}`))
+		case r.Method == http.MethodGet:
+			w.Write([]byte(`{"secret":{"identity":"database","version":"` + version + `"}}`))
+		case r.Method == http.MethodDelete:
+			body, _ := ioutil.ReadAll(r.Body)
+			deletes = append(deletes, r.URL.RawQuery+"|"+string(body))
+			w.Write([]byte(`{}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := sdk.NewClient(server.URL, "id", "secret", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &client, &deletes
+}
+
+func TestDeleteSendsExpectedVersion(t *testing.T) {
+	client, deletes := secretServer(t, "4")
+	secretClient := NewSecretClient(client)
+
+	err := secretClient.DeleteContext(context.Background(), "database", "4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*deletes) != 1 || (*deletes)[0] != "expected_version=4|" {
+		t.Errorf("deletes = %q, want expected_version in the query and no body", *deletes)
+	}
+}
+
+func TestDeleteRejectsOtherVersions(t *testing.T) {
+	client, deletes := secretServer(t, "5")
+	secretClient := NewSecretClient(client)
+
+	err := secretClient.DeleteContext(context.Background(), "database", "4")
+	if !errors.Is(err, sdk.ErrConflict) {
+		t.Fatalf("err = %v, want ErrConflict", err)
+	}
+
+	if len(*deletes) != 0 {
+		t.Errorf("secret deleted despite the version mismatch: %q", *deletes)
+	}
+}
@@ -0,0 +1,62 @@
+package dsm
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type SecretResponse struct {
+	Error    string `json:"error"`
+	Message  string `json:"message"`
+	Secret   Secret `json:"secret"`
+	Response struct {
+		Status    int    `json:"status"`
+		Message   string `json:"message"`
+		Error     bool   `json:"error"`
+		ErrorCode int    `json:"error_code"`
+	} `json:"response"`
+}
+
+func (r *SecretResponse) Unmarshal(msg []byte) error {
+	err := json.Unmarshal(msg, r)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+/**
+ * Validate the response of senhasegura server
+ */
+func (r *SecretResponse) Validate() error {
+	if r.Error != "" {
+		return fmt.Errorf(r.Message)
+	}
+
+	if r.Response.Error {
+		return fmt.Errorf(r.Response.Message)
+	}
+
+	return nil
+}
+
+func (r *SecretResponse) GetError() string {
+	return r.Error
+}
+
+func (r *SecretResponse) GetMessage() string {
+	return r.Message
+}
+
+func (r *SecretResponse) GetAccessToken() string {
+	return r.Message
+}
+
+func (r *SecretResponse) GetResponse() interface{} {
+	return r.Response
+}
+
+func (r *SecretResponse) GetEntity() interface{} {
+	return r.Secret
+}
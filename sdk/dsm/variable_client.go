@@ -1,6 +1,7 @@
 package dsm
 
 import (
+	"context"
 	"net/url"
 
 	sdk "github.com/senhasegura/dsmcli/sdk/iso"
@@ -26,9 +27,19 @@ func NewVariableClient(client *sdk.Client) VariableClient {
  * "POST /iso/cicd/variables"
  */
 func (a *VariableClient) Register(envVars string, mapVars string) (VariableResponse, error) {
+	return a.RegisterContext(context.Background(), envVars, mapVars)
+}
+
+/**
+ * Posts the variables like Register, aborting when ctx is done
+ */
+func (a *VariableClient) RegisterContext(ctx context.Context, envVars string, mapVars string) (VariableResponse, error) {
 	a.client.V("Posting variables in senhasegura...\n")
 
-	a.client.Authenticate()
+	err := a.client.AuthenticateContext(ctx)
+	if err != nil {
+		return VariableResponse{}, err
+	}
 
 	data := url.Values{
 		"env": {envVars},
@@ -36,7 +47,7 @@ func (a *VariableClient) Register(envVars string, mapVars string) (VariableRespo
 	}
 
 	var varResp VariableResponse
-	err := a.client.Post("/iso/cicd/variables", data, &varResp)
+	err = a.client.PostContext(ctx, "/iso/cicd/variables", data, &varResp)
 	if err != nil {
 		return VariableResponse{}, err
 	}
@@ -0,0 +1,280 @@
+package dsm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// volumeDataDir is the symlink pointing to the current version of a volume.
+	volumeDataDir = "..data"
+	// volumeMetadataFile describes the current version, under volumeDataDir.
+	volumeMetadataFile = "..metadata"
+	volumeDirMode      = 0500
+	volumeFileMode     = 0400
+)
+
+// VolumeMetadata is written to "..data/..metadata" on every update.
+type VolumeMetadata struct {
+	UpdatedAt time.Time         `json:"updated_at"`
+	Versions  map[string]string `json:"versions,omitempty"`
+}
+
+/**
+ * Writes files to dir the way kubelet updates secret volumes.
+ *
+ * The files are written to a new read only "..<timestamp>" directory,
+ * then the "..data" symlink is atomically swapped to it, so readers see
+ * either the previous or the new version, never a mix of both. Every top
+ * level entry of files is a symlink through "..data", and applications
+ * can watch "..data" to notice updates. Nothing is written when the
+ * current version already holds the same files.
+ *
+ * Entries of dir that are not managed through "..data" are left untouched.
+ */
+func writeVolume(dir string, files map[string][]byte, metadata VolumeMetadata) error {
+	for name := range files {
+		if !validVolumePath(name) {
+			return fmt.Errorf("Invalid file name '%s'", name)
+		}
+	}
+
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	if volumeUnchanged(dir, files) {
+		return nil
+	}
+
+	version, err := ioutil.TempDir(dir, ".."+time.Now().UTC().Format("2006_01_02_15_04_05."))
+	if err != nil {
+		return err
+	}
+
+	err = writeVolumeVersion(version, files, metadata)
+	if err != nil {
+		removeVolumeVersion(version)
+		return err
+	}
+
+	previous, _ := os.Readlink(filepath.Join(dir, volumeDataDir))
+
+	err = replaceSymlink(filepath.Base(version), filepath.Join(dir, volumeDataDir))
+	if err != nil {
+		removeVolumeVersion(version)
+		return err
+	}
+
+	err = linkVolumeEntries(dir, files)
+	if err != nil {
+		return err
+	}
+
+	if previous != "" && previous != filepath.Base(version) {
+		return removeVolumeVersion(filepath.Join(dir, previous))
+	}
+
+	return nil
+}
+
+/**
+ * Writes the files and metadata of a version, making it read only
+ */
+func writeVolumeVersion(version string, files map[string][]byte, metadata VolumeMetadata) error {
+	encoded, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(filepath.Join(version, volumeMetadataFile), encoded, volumeFileMode)
+	if err != nil {
+		return err
+	}
+
+	var dirs []string
+
+	for name, content := range files {
+		path := filepath.Join(version, name)
+
+		if parent := filepath.Dir(path); parent != version {
+			err = os.MkdirAll(parent, 0700)
+			if err != nil {
+				return err
+			}
+			dirs = append(dirs, parent)
+		}
+
+		err = ioutil.WriteFile(path, content, volumeFileMode)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Deepest directories first, so their parents are still writable
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	dirs = append(dirs, version)
+
+	for _, d := range dirs {
+		err = os.Chmod(d, volumeDirMode)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/**
+ * Links every top level entry of files through "..data", removing links
+ * of entries that are gone
+ */
+func linkVolumeEntries(dir string, files map[string][]byte) error {
+	entries := make(map[string]bool)
+	for name := range files {
+		entries[strings.SplitN(filepath.ToSlash(name), "/", 2)[0]] = true
+	}
+
+	for entry := range entries {
+		path := filepath.Join(dir, entry)
+		target := filepath.Join(volumeDataDir, entry)
+
+		current, err := os.Readlink(path)
+		if err == nil && current == target {
+			continue
+		}
+
+		if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink == 0 {
+			return fmt.Errorf("%s already exists and is not managed by DSM CLI", path)
+		}
+
+		err = replaceSymlink(target, path)
+		if err != nil {
+			return err
+		}
+	}
+
+	names, err := readDirNames(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if entries[name] || strings.HasPrefix(name, "..") {
+			continue
+		}
+
+		target, err := os.Readlink(filepath.Join(dir, name))
+		if err == nil && strings.HasPrefix(target, volumeDataDir+string(filepath.Separator)) {
+			err = os.Remove(filepath.Join(dir, name))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+/**
+ * Reports whether the current version of the volume holds exactly files
+ */
+func volumeUnchanged(dir string, files map[string][]byte) bool {
+	data := filepath.Join(dir, volumeDataDir)
+	count := 0
+
+	err := filepath.Walk(data+string(filepath.Separator), func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		name, err := filepath.Rel(data, path)
+		if err != nil || name == volumeMetadataFile {
+			return err
+		}
+
+		content, ok := files[name]
+		if !ok {
+			return fmt.Errorf("%s was removed", name)
+		}
+
+		current, err := ioutil.ReadFile(path)
+		if err != nil || !bytes.Equal(current, content) {
+			return fmt.Errorf("%s changed", name)
+		}
+
+		count++
+		return nil
+	})
+
+	return err == nil && count == len(files)
+}
+
+/**
+ * Points link to target, replacing any previous link atomically
+ */
+func replaceSymlink(target string, link string) error {
+	// Names starting with ".." are never used by files of the volume
+	tmp := filepath.Join(filepath.Dir(link), ".."+strings.TrimPrefix(filepath.Base(link), "..")+"_tmp")
+
+	os.Remove(tmp)
+
+	err := os.Symlink(target, tmp)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmp, link)
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return nil
+}
+
+/**
+ * Removes a version directory, making it writable first
+ */
+func removeVolumeVersion(version string) error {
+	filepath.Walk(version, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() {
+			os.Chmod(path, 0700)
+		}
+		return nil
+	})
+
+	return os.RemoveAll(version)
+}
+
+func validVolumePath(name string) bool {
+	if name == "" || filepath.IsAbs(name) || filepath.Clean(name) != name {
+		return false
+	}
+
+	for _, part := range strings.Split(filepath.ToSlash(name), "/") {
+		if part == "" || part == "." || strings.HasPrefix(part, "..") {
+			return false
+		}
+	}
+
+	return true
+}
+
+func readDirNames(dir string) ([]string, error) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+
+	return d.Readdirnames(-1)
+}
@@ -0,0 +1,210 @@
+package dsm
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+/**
+ * Returns a directory for a volume, removing its read only versions once
+ * the test is done
+ */
+func volumeDir(t *testing.T) string {
+	t.Helper()
+
+	// Symbolic links need extra privileges on Windows
+	if runtime.GOOS == "windows" {
+		t.Skip("volumes are only written on Unix systems")
+	}
+
+	dir := t.TempDir()
+	t.Cleanup(func() {
+		names, _ := readDirNames(dir)
+		for _, name := range names {
+			removeVolumeVersion(filepath.Join(dir, name))
+		}
+	})
+
+	return dir
+}
+
+func currentVersion(t *testing.T, dir string) string {
+	t.Helper()
+
+	version, err := os.Readlink(filepath.Join(dir, volumeDataDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return version
+}
+
+func checkVolumeFile(t *testing.T, dir string, name string, want string) {
+	t.Helper()
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(content) != want {
+		t.Errorf("%s = %q, want %q", name, content, want)
+	}
+}
+
+func checkMode(t *testing.T, path string, want os.FileMode) {
+	t.Helper()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != want {
+		t.Errorf("%s has mode %o, want %o", path, info.Mode().Perm(), want)
+	}
+}
+
+func TestWriteVolumeSwapsVersions(t *testing.T) {
+	dir := volumeDir(t)
+
+	err := writeVolume(dir, map[string][]byte{
+		"database/DB_PASSWORD": []byte("one"),
+		"api/API_TOKEN":        []byte("token"),
+	}, VolumeMetadata{UpdatedAt: time.Now().UTC(), Versions: map[string]string{"database": "1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := currentVersion(t, dir)
+	checkVolumeFile(t, dir, "database/DB_PASSWORD", "one")
+	checkVolumeFile(t, dir, "api/API_TOKEN", "token")
+
+	err = writeVolume(dir, map[string][]byte{
+		"database/DB_PASSWORD": []byte("two"),
+	}, VolumeMetadata{UpdatedAt: time.Now().UTC(), Versions: map[string]string{"database": "2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second := currentVersion(t, dir)
+	if second == first {
+		t.Fatalf("..data still points to %s", first)
+	}
+
+	if !strings.HasPrefix(second, "..") || strings.Contains(second, string(filepath.Separator)) {
+		t.Errorf("..data points to %s, want a hidden version next to it", second)
+	}
+
+	// Entries are read through ..data, so they switch with it
+	target, err := os.Readlink(filepath.Join(dir, "database"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != filepath.Join(volumeDataDir, "database") {
+		t.Errorf("database links to %s, want it through ..data", target)
+	}
+	checkVolumeFile(t, dir, "database/DB_PASSWORD", "two")
+
+	if _, err := os.Lstat(filepath.Join(dir, "api")); !os.IsNotExist(err) {
+		t.Errorf("link to the removed api entry was not pruned: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, first)); !os.IsNotExist(err) {
+		t.Errorf("previous version %s was not removed: %v", first, err)
+	}
+
+	names, err := readDirNames(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 3 {
+		t.Errorf("volume holds %q, want only ..data, database and the current version", names)
+	}
+
+	checkMode(t, filepath.Join(dir, second), volumeDirMode)
+	checkMode(t, filepath.Join(dir, second, "database"), volumeDirMode)
+	checkMode(t, filepath.Join(dir, second, "database", "DB_PASSWORD"), volumeFileMode)
+	checkMode(t, filepath.Join(dir, second, volumeMetadataFile), volumeFileMode)
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, volumeDataDir, volumeMetadataFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var metadata VolumeMetadata
+	err = json.Unmarshal(content, &metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Versions["database"] != "2" {
+		t.Errorf("metadata versions = %v, want database at version 2", metadata.Versions)
+	}
+}
+
+func TestWriteVolumeUnchanged(t *testing.T) {
+	dir := volumeDir(t)
+	files := map[string][]byte{"database/DB_PASSWORD": []byte("one")}
+
+	err := writeVolume(dir, files, VolumeMetadata{UpdatedAt: time.Now().UTC()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := currentVersion(t, dir)
+
+	err = writeVolume(dir, files, VolumeMetadata{UpdatedAt: time.Now().UTC()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if second := currentVersion(t, dir); second != first {
+		t.Errorf("unchanged files swapped ..data from %s to %s", first, second)
+	}
+}
+
+func TestWriteVolumeKeepsUnmanagedEntries(t *testing.T) {
+	dir := volumeDir(t)
+
+	err := ioutil.WriteFile(filepath.Join(dir, "README"), []byte("kept"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = writeVolume(dir, map[string][]byte{"database/DB_PASSWORD": []byte("one")}, VolumeMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkVolumeFile(t, dir, "README", "kept")
+
+	// A file DSM CLI did not write is never replaced by a link
+	err = writeVolume(dir, map[string][]byte{"README": []byte("secret")}, VolumeMetadata{})
+	if err == nil {
+		t.Error("unmanaged file replaced")
+	}
+	checkVolumeFile(t, dir, "README", "kept")
+}
+
+func TestWriteVolumeRejectsInvalidNames(t *testing.T) {
+	dir := volumeDir(t)
+
+	for _, name := range []string{"", "/etc/passwd", "../escape", "..data", "a/../b", "a//b"} {
+		err := writeVolume(dir, map[string][]byte{name: []byte("value")}, VolumeMetadata{})
+		if err == nil {
+			t.Errorf("name %q accepted", name)
+		}
+	}
+
+	names, err := readDirNames(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 0 {
+		t.Errorf("invalid names wrote %q", names)
+	}
+}
@@ -1,28 +1,56 @@
 package iso
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
-	"strconv"
 	"strings"
+	"time"
 )
 
+const tokenResource = "/iso/oauth2/token"
+
+// tokenExpirySkew is how long before its expiration an access token stops
+// being reused, so it does not expire while a request is in flight.
+const tokenExpirySkew = 30 * time.Second
+
 type Client struct {
 	url          string
 	clientID     string
 	clientSecret string
 	accessToken  string
+	tokenExpiry  time.Time
+	tokenCache   string
+	httpClient   *http.Client
+	retry        RetryPolicy
 	Verbose      bool
 }
 
+// Option customizes a Client built by NewClient.
+type Option func(*Client) error
+
+/**
+ * Verifies senhasegura and presents client certificates as described by opts
+ */
+func WithTLS(opts TLSOptions) Option {
+	return func(c *Client) error {
+		config, err := opts.Config()
+		if err != nil {
+			return err
+		}
+
+		c.httpClient = newHTTPClient(config)
+		return nil
+	}
+}
+
 /**
  * Contructor for client object
  */
-func NewClient(senhaseguraUrl string, clientID string, clientSecret string, verbose bool) (Client, error) {
+func NewClient(senhaseguraUrl string, clientID string, clientSecret string, verbose bool, opts ...Option) (Client, error) {
 	url := strings.Trim(string(senhaseguraUrl), "\n ")
 	if url == "" {
 		return Client{}, fmt.Errorf("URL cannot be null")
@@ -42,9 +70,17 @@ func NewClient(senhaseguraUrl string, clientID string, clientSecret string, verb
 		url:          url,
 		clientID:     clientID,
 		clientSecret: clientSecret,
+		httpClient:   defaultHTTPClient,
+		retry:        DefaultRetryPolicy,
 		Verbose:      verbose,
 	}
 
+	for _, opt := range opts {
+		if err := opt(&c); err != nil {
+			return Client{}, err
+		}
+	}
+
 	return c, nil
 }
 
@@ -61,16 +97,33 @@ func (c *Client) DefineNewCredentials(clientID string, clientSecret string) erro
 
 	c.clientID = clientID
 	c.clientSecret = clientSecret
+	c.accessToken = ""
+	c.tokenExpiry = time.Time{}
 	return nil
 }
 
 /**
  * Performs authetication on senhasegura DevSecOps API
  */
-func (c *Client) Authenticate() {
-	c.V("Trying to authenticate on senhasegura DevSecOps API\n")
+func (c *Client) Authenticate() error {
+	return c.AuthenticateContext(context.Background())
+}
+
+/**
+ * Requests a new access token unless the current one is still valid
+ */
+func (c *Client) AuthenticateContext(ctx context.Context) error {
+	if c.hasValidToken() {
+		c.V("Reusing access token\n")
+		return nil
+	}
+
+	if c.loadCachedToken() {
+		c.V("Using cached access token\n")
+		return nil
+	}
 
-	resource := "/iso/oauth2/token"
+	c.V("Trying to authenticate on senhasegura DevSecOps API\n")
 
 	data := url.Values{}
 	data.Set("grant_type", "client_credentials")
@@ -79,14 +132,44 @@ func (c *Client) Authenticate() {
 
 	var oauth2Resp Oauth2Response
 
-	err := c.Post(resource, data, &oauth2Resp)
+	c.accessToken = ""
+	err := c.PostContext(ctx, tokenResource, data, &oauth2Resp)
 	if err != nil {
-		log.Fatal("Error trying to authenticate: " + err.Error())
+		return fmt.Errorf("Error trying to authenticate: %w", err)
 	}
 
 	c.accessToken = "Bearer " + oauth2Resp.GetAccessToken()
+	c.tokenExpiry = time.Time{}
+	if oauth2Resp.ExpiresIn > 0 {
+		lifetime := time.Duration(oauth2Resp.ExpiresIn) * time.Second
+		skew := tokenExpirySkew
+		if lifetime <= 2*skew {
+			skew = lifetime / 2
+		}
+		c.tokenExpiry = time.Now().Add(lifetime - skew)
+	}
+
+	c.saveCachedToken()
 
 	c.V("Authenticated successfully\n")
+	return nil
+}
+
+/**
+ * Tokens without a known lifetime are reused until senhasegura rejects them
+ */
+func (c *Client) hasValidToken() bool {
+	if c.accessToken == "" {
+		return false
+	}
+
+	return c.tokenExpiry.IsZero() || time.Now().Before(c.tokenExpiry)
+}
+
+func (c *Client) invalidateToken() {
+	c.accessToken = ""
+	c.tokenExpiry = time.Time{}
+	c.removeCachedToken()
 }
 
 func (c *Client) V(format string, a ...interface{}) {
@@ -98,61 +181,227 @@ func (c *Client) V(format string, a ...interface{}) {
 /**
  * Performs a post request on senhasegura server
  */
-func (c Client) Post(resource string, data url.Values, responseObj ResponseInterface) error {
-	return c.call(http.MethodPost, resource, data, responseObj)
+func (c *Client) Post(resource string, data url.Values, responseObj ResponseInterface) error {
+	return c.PostContext(context.Background(), resource, data, responseObj)
+}
+
+/**
+ * Performs a post request on senhasegura server, aborting it when ctx is done
+ */
+func (c *Client) PostContext(ctx context.Context, resource string, data url.Values, responseObj ResponseInterface) error {
+	return c.call(ctx, http.MethodPost, resource, data, responseObj)
 }
 
 /**
  * Performs a get request on senhasegura server
  */
-func (c Client) Get(resource string, data url.Values, responseObj ResponseInterface) error {
-	return c.call(http.MethodGet, resource, data, responseObj)
+func (c *Client) Get(resource string, data url.Values, responseObj ResponseInterface) error {
+	return c.GetContext(context.Background(), resource, data, responseObj)
 }
 
 /**
- * Performs a request on senhasegura server
+ * Performs a get request on senhasegura server, aborting it when ctx is done
  */
-func (c Client) call(method string, resource string, data url.Values, responseObj ResponseInterface) error {
-	headers := make(map[string]string)
-	if c.accessToken != "" {
-		headers["Authorization"] = c.accessToken
-	}
-	headers["Content-Type"] = "application/x-www-form-urlencoded"
-	headers["Content-Length"] = strconv.Itoa(len(data.Encode()))
+func (c *Client) GetContext(ctx context.Context, resource string, data url.Values, responseObj ResponseInterface) error {
+	return c.call(ctx, http.MethodGet, resource, data, responseObj)
+}
+
+/**
+ * Performs a put request on senhasegura server
+ */
+func (c *Client) Put(resource string, data url.Values, responseObj ResponseInterface) error {
+	return c.PutContext(context.Background(), resource, data, responseObj)
+}
 
-	responseData, err := DoRequest(c.url, resource, data, headers, method)
+/**
+ * Performs a put request on senhasegura server, aborting it when ctx is done
+ */
+func (c *Client) PutContext(ctx context.Context, resource string, data url.Values, responseObj ResponseInterface) error {
+	return c.call(ctx, http.MethodPut, resource, data, responseObj)
+}
+
+/**
+ * Performs a delete request on senhasegura server
+ */
+func (c *Client) Delete(resource string, data url.Values, responseObj ResponseInterface) error {
+	return c.DeleteContext(context.Background(), resource, data, responseObj)
+}
+
+/**
+ * Performs a delete request on senhasegura server, aborting it when ctx is done
+ */
+func (c *Client) DeleteContext(ctx context.Context, resource string, data url.Values, responseObj ResponseInterface) error {
+	return c.call(ctx, http.MethodDelete, resource, data, responseObj)
+}
+
+/**
+ * Performs a request on senhasegura server
+ */
+func (c *Client) call(ctx context.Context, method string, resource string, data url.Values, responseObj ResponseInterface) error {
+	resp, responseData, err := c.send(ctx, method, resource, data)
 	if err != nil {
 		return err
 	}
 
+	// The token may have been revoked or expired early, authenticate again once
+	if resp.StatusCode == http.StatusUnauthorized && resource != tokenResource && c.accessToken != "" {
+		c.V("Access token rejected, authenticating again\n")
+
+		c.invalidateToken()
+		err = c.AuthenticateContext(ctx)
+		if err != nil {
+			return err
+		}
+
+		resp, responseData, err = c.send(ctx, method, resource, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return newAPIError(resp, method, resource, responseData)
+	}
+
 	err = responseObj.Unmarshal(responseData)
 	if err != nil {
-		return err
+		return fmt.Errorf("Invalid response from %s %s: %w", method, resource, err)
 	}
 
 	err = responseObj.Validate()
 	if err != nil {
-		return err
+		apiErr := newAPIError(resp, method, resource, responseData)
+		if apiErr.Message == "" {
+			apiErr.Message = err.Error()
+		}
+		return apiErr
 	}
 
 	return nil
 }
 
+/**
+ * Sends a request, retrying transient failures of idempotent requests
+ */
+func (c *Client) send(ctx context.Context, method string, resource string, data url.Values) (*http.Response, []byte, error) {
+	headers := make(map[string]string)
+	if c.accessToken != "" {
+		headers["Authorization"] = c.accessToken
+	}
+	if !queryMethod(method) {
+		headers["Content-Type"] = "application/x-www-form-urlencoded"
+	}
+
+	retryable := isRetryable(method, resource)
+
+	for attempt := 1; ; attempt++ {
+		resp, responseData, err := doRequest(ctx, c.httpClient, c.url, resource, data, headers, method)
+		if err == nil && !isTransientStatus(resp.StatusCode) {
+			return resp, responseData, nil
+		}
+
+		if !retryable || ctx.Err() != nil {
+			return resp, responseData, err
+		}
+
+		wait, ok := c.retry.delay(attempt, resp)
+		if !ok {
+			return resp, responseData, err
+		}
+
+		var reason string
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+		}
+		c.V("Attempt %d/%d of %s %s failed: %s, retrying in %s\n", attempt, c.retry.MaxAttempts, method, resource, reason, wait)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+var defaultHTTPClient = newHTTPClient(nil)
+
+/**
+ * Builds an HTTP client verifying servers as described by opts, for
+ * requests made with DoRequestWithClient
+ */
+func NewHTTPClient(opts TLSOptions) (*http.Client, error) {
+	config, err := opts.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	return newHTTPClient(config), nil
+}
+
+func newHTTPClient(config *tls.Config) *http.Client {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.TLSClientConfig = config
+
+	return &http.Client{Transport: tr}
+}
+
+/**
+ * Performs a request verifying the server certificate against the system roots.
+ * Responses with an error status are returned as *APIError.
+ */
 func DoRequest(host string, resource string, data url.Values, headers map[string]string, method string) ([]byte, error) {
-	u, err := url.ParseRequestURI(host)
+	return DoRequestContext(context.Background(), host, resource, data, headers, method)
+}
+
+/**
+ * Performs a request like DoRequest, aborting it when ctx is done
+ */
+func DoRequestContext(ctx context.Context, host string, resource string, data url.Values, headers map[string]string, method string) ([]byte, error) {
+	return DoRequestWithClient(ctx, defaultHTTPClient, host, resource, data, headers, method)
+}
+
+/**
+ * Performs a request like DoRequestContext with the given HTTP client,
+ * for servers signed by a private CA or requiring client certificates
+ */
+func DoRequestWithClient(ctx context.Context, httpClient *http.Client, host string, resource string, data url.Values, headers map[string]string, method string) ([]byte, error) {
+	resp, responseData, err := doRequest(ctx, httpClient, host, resource, data, headers, method)
 	if err != nil {
 		return nil, err
 	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return responseData, newAPIError(resp, method, resource, responseData)
+	}
+
+	return responseData, nil
+}
+
+/**
+ * Performs a single request, returning the response with its body already
+ * read. GET and DELETE requests carry data in the query string, as servers
+ * and proxies may ignore or reject their body.
+ */
+func doRequest(ctx context.Context, httpClient *http.Client, host string, resource string, data url.Values, headers map[string]string, method string) (*http.Response, []byte, error) {
+	u, err := url.ParseRequestURI(host)
+	if err != nil {
+		return nil, nil, err
+	}
 	u.Path = resource
-	urlStr := u.String()
 
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	body := data.Encode()
+	if queryMethod(method) {
+		u.RawQuery = body
+		body = ""
 	}
-	httpClient := &http.Client{Transport: tr}
-	r, err := http.NewRequest(method, urlStr, strings.NewReader(data.Encode()))
+
+	r, err := http.NewRequestWithContext(ctx, method, u.String(), strings.NewReader(body))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	for k, v := range headers {
@@ -161,13 +410,18 @@ func DoRequest(host string, resource string, data url.Values, headers map[string
 
 	resp, err := httpClient.Do(r)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	defer resp.Body.Close()
 
 	responseData, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return responseData, nil
+	return resp, responseData, nil
+}
+
+func queryMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodDelete
 }
@@ -0,0 +1,60 @@
+package iso
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestDoRequestSendsData(t *testing.T) {
+	tests := []struct {
+		method    string
+		wantQuery string
+		wantBody  string
+	}{
+		{http.MethodGet, "application=app&environment=prod", ""},
+		{http.MethodDelete, "application=app&environment=prod", ""},
+		{http.MethodPost, "", "application=app&environment=prod"},
+		{http.MethodPut, "", "application=app&environment=prod"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			var query, body, contentType string
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				content, _ := ioutil.ReadAll(r.Body)
+				query, body, contentType = r.URL.RawQuery, string(content), r.Header.Get("Content-Type")
+				w.Write([]byte(`{}`))
+			}))
+			defer server.Close()
+
+			client, err := NewClient(server.URL, "id", "secret", false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data := url.Values{"application": {"app"}, "environment": {"prod"}}
+
+			_, _, err = client.send(context.Background(), tt.method, "/iso/dapp/Application", data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if query != tt.wantQuery {
+				t.Errorf("query = %q, want %q", query, tt.wantQuery)
+			}
+
+			if body != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+
+			if (tt.wantBody == "") != (contentType == "") {
+				t.Errorf("Content-Type = %q with body %q", contentType, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package iso
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrUnauthorized matches errors caused by missing, invalid or
+	// insufficient credentials.
+	ErrUnauthorized = errors.New("authentication failed")
+
+	// ErrNotFound matches errors for resources unknown to senhasegura.
+	ErrNotFound = errors.New("resource not found")
+
+	// ErrRateLimited matches errors for requests throttled by senhasegura.
+	ErrRateLimited = errors.New("rate limited")
+
+	// ErrConflict matches errors for changes based on an outdated version
+	// of a resource.
+	ErrConflict = errors.New("version conflict")
+)
+
+// APIError describes a request rejected by senhasegura, either through the
+// HTTP status code or through the error fields of the response body.
+type APIError struct {
+	StatusCode int
+	Method     string
+	Endpoint   string
+	ErrorCode  int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	message := e.Message
+	if message == "" {
+		message = http.StatusText(e.StatusCode)
+	}
+
+	if e.ErrorCode != 0 {
+		return fmt.Sprintf("%s %s: %s (status %d, error code %d)", e.Method, e.Endpoint, message, e.StatusCode, e.ErrorCode)
+	}
+
+	return fmt.Sprintf("%s %s: %s (status %d)", e.Method, e.Endpoint, message, e.StatusCode)
+}
+
+/**
+ * Allows errors.Is to compare an APIError with the sentinel errors
+ */
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict || e.StatusCode == http.StatusPreconditionFailed
+	}
+
+	return false
+}
+
+// errorBody holds the error fields shared by every senhasegura response.
+type errorBody struct {
+	Error    string `json:"error"`
+	Message  string `json:"message"`
+	Response struct {
+		Status    int    `json:"status"`
+		Message   string `json:"message"`
+		Error     bool   `json:"error"`
+		ErrorCode int    `json:"error_code"`
+	} `json:"response"`
+}
+
+/**
+ * Builds an APIError from a response, using its body when it is a
+ * senhasegura error payload and the status line otherwise
+ */
+func newAPIError(resp *http.Response, method string, resource string, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Method:     method,
+		Endpoint:   resource,
+	}
+
+	var payload errorBody
+	if json.Unmarshal(body, &payload) != nil {
+		return apiErr
+	}
+
+	if payload.Response.Status >= http.StatusBadRequest {
+		apiErr.StatusCode = payload.Response.Status
+	}
+	apiErr.ErrorCode = payload.Response.ErrorCode
+
+	switch {
+	case payload.Response.Message != "":
+		apiErr.Message = payload.Response.Message
+	case payload.Message != "":
+		apiErr.Message = payload.Message
+	default:
+		apiErr.Message = payload.Error
+	}
+
+	return apiErr
+}
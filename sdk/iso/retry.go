@@ -0,0 +1,125 @@
+package iso
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RetryPolicy controls how requests failing with transient errors are
+// attempted again.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	MaxAttempts int
+
+	// BaseDelay is doubled on every attempt up to MaxDelay. A Retry-After
+	// header longer than MaxDelay stops the retries.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    10 * time.Second,
+}
+
+// safeResources are endpoints whose POST may be repeated without side effects.
+var safeResources = map[string]bool{
+	tokenResource:           true,
+	"/iso/dapp/Application": true,
+}
+
+var (
+	jitterMu sync.Mutex
+	jitter   = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+/**
+ * Retries transient failures as described by policy
+ */
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) error {
+		c.retry = policy
+		return nil
+	}
+}
+
+/**
+ * Only idempotent requests can be sent again safely
+ */
+func isRetryable(method string, resource string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+
+	return safeResources[resource]
+}
+
+func isTransientStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusRequestTimeout,
+		http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+/**
+ * Returns how long to wait before the given attempt (starting at 1 for
+ * the first retry) and whether it should be made at all
+ */
+func (p RetryPolicy) delay(attempt int, resp *http.Response) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts {
+		return 0, false
+	}
+
+	if resp != nil {
+		if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return wait, wait <= p.MaxDelay
+		}
+	}
+
+	backoff := p.BaseDelay << uint(attempt-1)
+	if backoff > p.MaxDelay || backoff <= 0 {
+		backoff = p.MaxDelay
+	}
+
+	// Equal jitter: wait at least half of the backoff
+	half := int64(backoff / 2)
+	if half <= 0 {
+		return backoff, true
+	}
+
+	jitterMu.Lock()
+	defer jitterMu.Unlock()
+
+	return time.Duration(half + jitter.Int63n(half+1)), true
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+
+	return 0, false
+}
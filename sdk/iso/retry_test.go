@@ -0,0 +1,150 @@
+package iso
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// failingServer answers the first failures requests with status, setting
+// Retry-After when retryAfter is not empty, and 200 afterwards.
+func failingServer(t *testing.T, failures int, status int, retryAfter string, onRequest func(attempt int)) (*httptest.Server, *int32) {
+	t.Helper()
+
+	var attempts int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempt := int(atomic.AddInt32(&attempts, 1))
+		if onRequest != nil {
+			onRequest(attempt)
+		}
+
+		if attempt <= failures {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(status)
+			return
+		}
+
+		w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &attempts
+}
+
+func newRetryClient(t *testing.T, server *httptest.Server, policy RetryPolicy) Client {
+	t.Helper()
+
+	client, err := NewClient(server.URL, "id", "secret", false, WithRetryPolicy(policy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client
+}
+
+var fastRetries = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 50 * time.Millisecond}
+
+func TestRetryTransientFailures(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		resource     string
+		failures     int
+		status       int
+		retryAfter   string
+		wantAttempts int
+		wantStatus   int
+	}{
+		{"GET after 502", http.MethodGet, "/iso/dapp/application", 2, http.StatusBadGateway, "", 3, http.StatusOK},
+		{"GET after 503", http.MethodGet, "/iso/dapp/application", 1, http.StatusServiceUnavailable, "", 2, http.StatusOK},
+		{"GET after 429 with Retry-After", http.MethodGet, "/iso/dapp/application", 2, http.StatusTooManyRequests, "0", 3, http.StatusOK},
+		{"GET after 429 with Retry-After past MaxDelay", http.MethodGet, "/iso/dapp/application", 1, http.StatusTooManyRequests, "3600", 1, http.StatusTooManyRequests},
+		{"GET after 500", http.MethodGet, "/iso/dapp/application", 1, http.StatusInternalServerError, "", 1, http.StatusInternalServerError},
+		{"DELETE after 503", http.MethodDelete, "/iso/sctm/secret/db", 1, http.StatusServiceUnavailable, "", 2, http.StatusOK},
+		{"POST token after 502", http.MethodPost, tokenResource, 1, http.StatusBadGateway, "", 2, http.StatusOK},
+		{"POST application after 429", http.MethodPost, "/iso/dapp/Application", 1, http.StatusTooManyRequests, "0", 2, http.StatusOK},
+		{"POST variables after 502", http.MethodPost, "/iso/cicd/variables", 1, http.StatusBadGateway, "", 1, http.StatusBadGateway},
+		{"POST variables after 503", http.MethodPost, "/iso/cicd/variables", 2, http.StatusServiceUnavailable, "", 1, http.StatusServiceUnavailable},
+		{"POST variables after 429 with Retry-After", http.MethodPost, "/iso/cicd/variables", 1, http.StatusTooManyRequests, "0", 1, http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, attempts := failingServer(t, tt.failures, tt.status, tt.retryAfter, nil)
+			client := newRetryClient(t, server, fastRetries)
+
+			resp, _, err := client.send(context.Background(), tt.method, tt.resource, url.Values{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := int(atomic.LoadInt32(attempts)); got != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRetryMaxAttempts(t *testing.T) {
+	for _, maxAttempts := range []int{1, 2, 5} {
+		t.Run(strconv.Itoa(maxAttempts), func(t *testing.T) {
+			server, attempts := failingServer(t, 100, http.StatusServiceUnavailable, "", nil)
+
+			policy := fastRetries
+			policy.MaxAttempts = maxAttempts
+			client := newRetryClient(t, server, policy)
+
+			resp, _, err := client.send(context.Background(), http.MethodGet, "/iso/dapp/application", url.Values{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := int(atomic.LoadInt32(attempts)); got != maxAttempts {
+				t.Errorf("attempts = %d, want %d", got, maxAttempts)
+			}
+
+			if resp.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+			}
+		})
+	}
+}
+
+func TestRetryStopsWhenContextIsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Cancel while the client waits for the backoff after the first attempt
+	server, attempts := failingServer(t, 100, http.StatusServiceUnavailable, "", func(attempt int) {
+		time.AfterFunc(20*time.Millisecond, cancel)
+	})
+	client := newRetryClient(t, server, RetryPolicy{MaxAttempts: 5, BaseDelay: 10 * time.Second, MaxDelay: 10 * time.Second})
+
+	start := time.Now()
+	_, _, err := client.send(ctx, http.MethodGet, "/iso/dapp/application", url.Values{})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want %v", err, context.Canceled)
+	}
+
+	if got := int(atomic.LoadInt32(attempts)); got != 1 {
+		t.Errorf("attempts = %d, want 1", got)
+	}
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("send returned after %s, want it to stop waiting when ctx is cancelled", elapsed)
+	}
+}
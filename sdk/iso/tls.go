@@ -0,0 +1,143 @@
+package iso
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// TLSOptions describes how the client verifies senhasegura and authenticates
+// itself at the TLS layer. The zero value verifies the server against the
+// system roots.
+type TLSOptions struct {
+	// InsecureSkipVerify disables certificate chain and host name
+	// verification. Pinned certificates are still enforced.
+	InsecureSkipVerify bool
+
+	// CAFile is a PEM bundle appended to the system roots.
+	CAFile string
+
+	// CertFile and KeyFile hold the PEM client certificate and key used
+	// for mutual TLS. Both must be set together.
+	CertFile string
+	KeyFile  string
+
+	// PinnedSHA256 lists hex encoded SHA-256 fingerprints of certificates
+	// accepted in the server chain. Colons are ignored. With verification
+	// enabled the pins are matched against the verified chains, otherwise
+	// against the server certificate only.
+	PinnedSHA256 []string
+}
+
+/**
+ * Builds the tls.Config described by the options
+ */
+func (o TLSOptions) Config() (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: o.InsecureSkipVerify,
+	}
+
+	if o.CAFile != "" {
+		pem, err := ioutil.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading CA bundle: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificates found in CA bundle '%s'", o.CAFile)
+		}
+
+		config.RootCAs = pool
+	}
+
+	if o.CertFile != "" || o.KeyFile != "" {
+		if o.CertFile == "" || o.KeyFile == "" {
+			return nil, fmt.Errorf("Client certificate and key must be provided together")
+		}
+
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error loading client certificate: %w", err)
+		}
+
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(o.PinnedSHA256) > 0 {
+		pins, err := parsePins(o.PinnedSHA256)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(pins) > 0 {
+			config.VerifyConnection = func(cs tls.ConnectionState) error {
+				return verifyPins(cs, pins)
+			}
+		}
+	}
+
+	return config, nil
+}
+
+func parsePins(values []string) ([][]byte, error) {
+	var pins [][]byte
+
+	for _, value := range values {
+		value = strings.ReplaceAll(strings.TrimSpace(value), ":", "")
+		if value == "" {
+			continue
+		}
+
+		pin, err := hex.DecodeString(value)
+		if err != nil || len(pin) != sha256.Size {
+			return nil, fmt.Errorf("Invalid SHA-256 certificate pin '%s'", value)
+		}
+
+		pins = append(pins, pin)
+	}
+
+	return pins, nil
+}
+
+/**
+ * Matches the pins against the certificates the server proved to hold.
+ *
+ * The server chooses which certificates it sends, so any of them could be
+ * a copy of the public pinned one. Only the chains built up to a trusted
+ * root are considered, or the leaf, whose key signed the handshake, when
+ * verification is disabled.
+ */
+func verifyPins(cs tls.ConnectionState, pins [][]byte) error {
+	var certs []*x509.Certificate
+
+	if len(cs.VerifiedChains) > 0 {
+		for _, chain := range cs.VerifiedChains {
+			certs = append(certs, chain...)
+		}
+	} else if len(cs.PeerCertificates) > 0 {
+		certs = cs.PeerCertificates[:1]
+	}
+
+	for _, cert := range certs {
+		sum := sha256.Sum256(cert.Raw)
+
+		for _, pin := range pins {
+			if bytes.Equal(sum[:], pin) {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("Server certificate does not match any pinned SHA-256 fingerprint")
+}
@@ -0,0 +1,166 @@
+package iso
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCert(t *testing.T, name string, parent *testCert, isCA bool) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCert{cert: cert, key: key}
+}
+
+func pin(c *testCert) string {
+	sum := sha256.Sum256(c.cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// serveChain starts a TLS server presenting leaf followed by extra.
+func serveChain(t *testing.T, leaf *testCert, extra ...*testCert) *httptest.Server {
+	t.Helper()
+
+	chain := [][]byte{leaf.cert.Raw}
+	for _, c := range extra {
+		chain = append(chain, c.cert.Raw)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: chain, PrivateKey: leaf.key}}}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func handshake(t *testing.T, server *httptest.Server, opts TLSOptions) error {
+	t.Helper()
+
+	config, err := opts.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := newHTTPClient(config).Get(server.URL)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+func writeCA(t *testing.T, ca *testCert) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestPinsWithoutVerification(t *testing.T) {
+	pinned := newTestCert(t, "senhasegura", nil, false)
+	attacker := newTestCert(t, "attacker", nil, false)
+
+	tests := []struct {
+		name    string
+		server  *httptest.Server
+		wantErr bool
+	}{
+		{"pinned leaf", serveChain(t, pinned), false},
+		{"attacker leaf with pinned certificate appended", serveChain(t, attacker, pinned), true},
+		{"attacker leaf", serveChain(t, attacker), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := handshake(t, tt.server, TLSOptions{InsecureSkipVerify: true, PinnedSHA256: []string{pin(pinned)}})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("handshake error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPinsWithVerification(t *testing.T) {
+	ca := newTestCert(t, "ca", nil, true)
+	pinned := newTestCert(t, "senhasegura", ca, false)
+	misissued := newTestCert(t, "misissued", ca, false)
+	caFile := writeCA(t, ca)
+
+	tests := []struct {
+		name    string
+		server  *httptest.Server
+		pins    []string
+		wantErr bool
+	}{
+		{"pinned leaf", serveChain(t, pinned), []string{pin(pinned)}, false},
+		{"pinned CA", serveChain(t, misissued), []string{pin(ca)}, false},
+		{"trusted leaf with pinned certificate appended", serveChain(t, misissued, pinned), []string{pin(pinned)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := handshake(t, tt.server, TLSOptions{CAFile: caFile, PinnedSHA256: tt.pins})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("handshake error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package iso
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type cachedToken struct {
+	AccessToken string    `json:"access_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+/**
+ * Keeps access tokens under dir so consecutive runs can reuse them.
+ * Entries are scoped by URL and client ID and encrypted with a key derived
+ * from the client secret, so they are useless without the credentials
+ * that produced them.
+ */
+func WithTokenCache(dir string) Option {
+	return func(c *Client) error {
+		c.tokenCache = dir
+		return nil
+	}
+}
+
+func (c *Client) tokenCachePath() string {
+	sum := sha256.Sum256([]byte(c.url + "\n" + c.clientID))
+	return filepath.Join(c.tokenCache, hex.EncodeToString(sum[:])+".token")
+}
+
+func (c *Client) tokenCacheCipher() (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte("dsmcli token cache\n" + c.clientSecret))
+
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+/**
+ * Loads a still valid access token from the cache, if there is one
+ */
+func (c *Client) loadCachedToken() bool {
+	if c.tokenCache == "" {
+		return false
+	}
+
+	content, err := ioutil.ReadFile(c.tokenCachePath())
+	if err != nil {
+		return false
+	}
+
+	aead, err := c.tokenCacheCipher()
+	if err != nil || len(content) < aead.NonceSize() {
+		return false
+	}
+
+	nonce, sealed := content[:aead.NonceSize()], content[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, sealed, []byte(c.url))
+	if err != nil {
+		c.V("Ignoring unreadable token cache entry\n")
+		return false
+	}
+
+	var token cachedToken
+	if err := json.Unmarshal(plain, &token); err != nil {
+		return false
+	}
+
+	if token.AccessToken == "" || !time.Now().Before(token.ExpiresAt) {
+		return false
+	}
+
+	c.accessToken = token.AccessToken
+	c.tokenExpiry = token.ExpiresAt
+	return true
+}
+
+/**
+ * Stores the current access token in the cache. Tokens without a known
+ * expiration are kept in memory only.
+ */
+func (c *Client) saveCachedToken() {
+	if c.tokenCache == "" || c.tokenExpiry.IsZero() {
+		return
+	}
+
+	err := c.writeCachedToken()
+	if err != nil {
+		c.V("Failed to cache access token: %s\n", err.Error())
+	}
+}
+
+func (c *Client) writeCachedToken() error {
+	plain, err := json.Marshal(cachedToken{AccessToken: c.accessToken, ExpiresAt: c.tokenExpiry})
+	if err != nil {
+		return err
+	}
+
+	aead, err := c.tokenCacheCipher()
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(c.tokenCache, 0700); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(c.tokenCache, ".token-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(aead.Seal(nonce, nonce, plain, []byte(c.url)))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("Error writing token cache: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), c.tokenCachePath())
+}
+
+func (c *Client) removeCachedToken() {
+	if c.tokenCache == "" {
+		return
+	}
+
+	os.Remove(c.tokenCachePath())
+}